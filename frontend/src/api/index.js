@@ -52,11 +52,38 @@ export const api = {
         return request.post('/batch/shorten', { urls })
     },
 
+    // 上传CSV创建批量导入任务
+    createImportJob(file) {
+        const form = new FormData()
+        form.append('file', file)
+        return request.post('/import/jobs', form)
+    },
+
+    // 查询导入任务进度
+    getImportJob(id) {
+        return request.get(`/import/jobs/${id}`)
+    },
+
+    // 查询短链接列表（游标分页）
+    listShortLinks(params) {
+        return request.get('/links', { params })
+    },
+
     // 获取短链接详情
     getShortLink(code) {
         return request.get(`/links/${code}`)
     },
 
+    // 更新短链接
+    updateShortLink(code, data) {
+        return request.patch(`/links/${code}`, data)
+    },
+
+    // 删除短链接
+    deleteShortLink(code) {
+        return request.delete(`/links/${code}`)
+    },
+
     // 获取访问统计
     getStats(code) {
         return request.get(`/stats/${code}`)
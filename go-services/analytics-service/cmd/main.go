@@ -85,6 +85,7 @@ func main() {
 	mux.HandleFunc("/api/analytics/browser/", analyticsHandler.GetBrowserStats)
 	mux.HandleFunc("/api/analytics/device/", analyticsHandler.GetDeviceStats)
 	mux.HandleFunc("/api/analytics/os/", analyticsHandler.GetOSStats)
+	mux.HandleFunc("/api/analytics/utm", analyticsHandler.GetUTMStats)
 
 	// 启动HTTP服务器
 	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
@@ -99,6 +100,7 @@ func main() {
 	fmt.Println("  ✓ GET  /api/analytics/browser/:code      - Browser stats")
 	fmt.Println("  ✓ GET  /api/analytics/device/:code       - Device stats")
 	fmt.Println("  ✓ GET  /api/analytics/os/:code           - OS stats")
+	fmt.Println("  ✓ GET  /api/analytics/utm                - UTM stats")
 	fmt.Println()
 	fmt.Println("📊 Kafka Consumer:")
 	fmt.Printf("  • Brokers: %v\n", c.Kafka.Brokers)
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"analytics-service/internal/model"
 	"analytics-service/internal/repo"
 	"analytics-service/internal/types"
 )
@@ -143,6 +145,57 @@ func (h *AnalyticsHandler) GetOSStats(w http.ResponseWriter, r *http.Request) {
 	h.successResponse(w, osList)
 }
 
+// GetUTMStats 按UTM参数分组统计访问次数
+// group_by 为 source|medium|campaign|term|content（默认 campaign），
+// 可按 short_code、user_id、source、medium、campaign 过滤
+func (h *AnalyticsHandler) GetUTMStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	groupBy := query.Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = "campaign"
+	case "source", "medium", "campaign", "term", "content":
+	default:
+		h.errorResponse(w, "invalid group_by: "+groupBy, http.StatusBadRequest)
+		return
+	}
+
+	// 获取日期范围参数，默认最近7天
+	startDate := query.Get("start_date")
+	endDate := query.Get("end_date")
+	if startDate == "" {
+		startDate = time.Now().AddDate(0, 0, -7).Format("2006-01-02")
+	}
+	if endDate == "" {
+		endDate = time.Now().Format("2006-01-02")
+	}
+
+	filter := model.UTMFilter{
+		ShortCode: query.Get("short_code"),
+		Source:    query.Get("source"),
+		Medium:    query.Get("medium"),
+		Campaign:  query.Get("campaign"),
+	}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || userID == 0 {
+			h.errorResponse(w, "invalid user_id: "+v, http.StatusBadRequest)
+			return
+		}
+		filter.UserID = userID
+	}
+
+	ctx := context.Background()
+	stats, err := h.repo.GetUTMStats(ctx, groupBy, filter, startDate, endDate)
+	if err != nil {
+		h.errorResponse(w, "failed to get utm stats", http.StatusInternalServerError)
+		return
+	}
+
+	h.successResponse(w, stats)
+}
+
 // successResponse 成功响应
 func (h *AnalyticsHandler) successResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
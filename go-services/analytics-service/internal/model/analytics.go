@@ -73,14 +73,60 @@ func (AnalyticsOS) TableName() string {
 	return "analytics_os"
 }
 
+// AnalyticsUTM 按UTM参数组合的每日统计
+type AnalyticsUTM struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShortCode   string    `gorm:"index;size:20;not null" json:"short_code"`
+	UserID      uint64    `gorm:"index;not null;default:0" json:"user_id"` // 短链创建者，匿名创建时为0
+	Date        string    `gorm:"index;size:10;not null" json:"date"`      // YYYY-MM-DD
+	UTMSource   string    `gorm:"size:100;not null" json:"utm_source"`
+	UTMMedium   string    `gorm:"size:100;not null" json:"utm_medium"`
+	UTMCampaign string    `gorm:"index;size:100;not null" json:"utm_campaign"`
+	UTMTerm     string    `gorm:"size:100;not null" json:"utm_term"`
+	UTMContent  string    `gorm:"size:100;not null" json:"utm_content"`
+	VisitCount  int64     `gorm:"default:0" json:"visit_count"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AnalyticsUTM) TableName() string {
+	return "analytics_utm"
+}
+
+// UTMStat 按某个UTM参数分组的访问次数
+type UTMStat struct {
+	Value      string `json:"value"`
+	VisitCount int64  `json:"visit_count"`
+}
+
+// UTMFilter UTM统计的过滤条件，空字段表示不过滤
+type UTMFilter struct {
+	ShortCode string
+	UserID    uint64
+	Source    string
+	Medium    string
+	Campaign  string
+}
+
 // VisitEvent 访问事件（从Kafka接收）
 type VisitEvent struct {
-	ShortCode  string `json:"short_code"`
-	IP         string `json:"ip"`
-	UserAgent  string `json:"user_agent"`
-	Referer    string `json:"referer"`
-	DeviceType string `json:"device_type"`
-	Browser    string `json:"browser"`
-	OS         string `json:"os"`
-	Timestamp  int64  `json:"timestamp"`
+	ShortCode   string `json:"short_code"`
+	UserID      uint64 `json:"user_id,omitempty"` // 短链创建者，匿名创建时为0
+	IP          string `json:"ip"`
+	UserAgent   string `json:"user_agent"`
+	Referer     string `json:"referer"`
+	DeviceType  string `json:"device_type"`
+	Browser     string `json:"browser"`
+	OS          string `json:"os"`
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// HasUTM 是否带有UTM参数
+func (e *VisitEvent) HasUTM() bool {
+	return e.UTMSource != "" || e.UTMMedium != "" || e.UTMCampaign != "" || e.UTMTerm != "" || e.UTMContent != ""
 }
@@ -33,6 +33,19 @@ type AnalyticsRepo interface {
 	// 操作系统统计
 	UpsertOS(ctx context.Context, os *model.AnalyticsOS) error
 	GetTopOS(ctx context.Context, shortCode string, limit int) ([]*model.AnalyticsOS, error)
+
+	// UTM统计
+	UpsertUTM(ctx context.Context, utm *model.AnalyticsUTM) error
+	GetUTMStats(ctx context.Context, groupBy string, filter model.UTMFilter, startDate, endDate string) ([]*model.UTMStat, error)
+}
+
+// utmGroupColumns UTM统计可分组的参数及对应的列
+var utmGroupColumns = map[string]string{
+	"source":   "utm_source",
+	"medium":   "utm_medium",
+	"campaign": "utm_campaign",
+	"term":     "utm_term",
+	"content":  "utm_content",
 }
 
 // analyticsRepo 实现
@@ -56,6 +69,7 @@ func NewAnalyticsRepo(dsn string) (AnalyticsRepo, error) {
 		&model.AnalyticsBrowser{},
 		&model.AnalyticsDevice{},
 		&model.AnalyticsOS{},
+		&model.AnalyticsUTM{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
@@ -177,3 +191,45 @@ func (r *analyticsRepo) GetTopOS(ctx context.Context, shortCode string, limit in
 		Find(&osList).Error
 	return osList, err
 }
+
+// UpsertUTM 插入或更新UTM统计
+func (r *analyticsRepo) UpsertUTM(ctx context.Context, utm *model.AnalyticsUTM) error {
+	return r.db.WithContext(ctx).
+		Where("short_code = ? AND user_id = ? AND date = ? AND utm_source = ? AND utm_medium = ? AND utm_campaign = ? AND utm_term = ? AND utm_content = ?",
+			utm.ShortCode, utm.UserID, utm.Date, utm.UTMSource, utm.UTMMedium, utm.UTMCampaign, utm.UTMTerm, utm.UTMContent).
+		Assign(map[string]interface{}{
+			"visit_count": gorm.Expr("visit_count + ?", 1),
+		}).
+		FirstOrCreate(utm).Error
+}
+
+// GetUTMStats 按某个UTM参数分组统计日期范围内的访问次数
+func (r *analyticsRepo) GetUTMStats(ctx context.Context, groupBy string, filter model.UTMFilter, startDate, endDate string) ([]*model.UTMStat, error) {
+	column, ok := utmGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.AnalyticsUTM{}).
+		Select(column+" AS value, SUM(visit_count) AS visit_count").
+		Where("date BETWEEN ? AND ?", startDate, endDate)
+	if filter.ShortCode != "" {
+		db = db.Where("short_code = ?", filter.ShortCode)
+	}
+	if filter.UserID != 0 {
+		db = db.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Source != "" {
+		db = db.Where("utm_source = ?", filter.Source)
+	}
+	if filter.Medium != "" {
+		db = db.Where("utm_medium = ?", filter.Medium)
+	}
+	if filter.Campaign != "" {
+		db = db.Where("utm_campaign = ?", filter.Campaign)
+	}
+
+	var stats []*model.UTMStat
+	err := db.Group(column).Order("visit_count DESC").Scan(&stats).Error
+	return stats, err
+}
@@ -30,7 +30,7 @@ func (a *Aggregator) ProcessVisitEvent(ctx context.Context, event *model.VisitEv
 	hour := visitTime.Format("2006-01-02 15")
 
 	// 并发处理多个聚合任务
-	errChan := make(chan error, 5)
+	errChan := make(chan error, 6)
 
 	// 1. 每日统计
 	go func() {
@@ -95,9 +95,29 @@ func (a *Aggregator) ProcessVisitEvent(ctx context.Context, event *model.VisitEv
 		}
 	}()
 
+	// 6. UTM统计
+	go func() {
+		if event.HasUTM() {
+			utm := &model.AnalyticsUTM{
+				ShortCode:   event.ShortCode,
+				UserID:      event.UserID,
+				Date:        date,
+				UTMSource:   event.UTMSource,
+				UTMMedium:   event.UTMMedium,
+				UTMCampaign: event.UTMCampaign,
+				UTMTerm:     event.UTMTerm,
+				UTMContent:  event.UTMContent,
+				VisitCount:  1,
+			}
+			errChan <- a.repo.UpsertUTM(ctx, utm)
+		} else {
+			errChan <- nil
+		}
+	}()
+
 	// 收集错误
 	var errors []error
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		if err := <-errChan; err != nil {
 			errors = append(errors, err)
 			log.Printf("⚠️  Aggregation error: %v", err)
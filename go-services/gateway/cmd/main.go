@@ -89,7 +89,10 @@ func main() {
 	fmt.Println("📋 Route Table:")
 	fmt.Println("  ✓ GET  /health                  - Health check")
 	fmt.Println("  ✓ POST /api/shorten             - Create short link (Auth)")
+	fmt.Println("  ✓ GET  /api/links               - List/search links (Auth)")
 	fmt.Println("  ✓ GET  /api/links/:code         - Get link details (Auth)")
+	fmt.Println("  ✓ PATCH /api/links/:code        - Update link (Auth)")
+	fmt.Println("  ✓ DELETE /api/links/:code       - Delete link (Auth)")
 	fmt.Println("  ✓ POST /api/batch/shorten       - Batch create (Auth)")
 	fmt.Println("  ✓ GET  /api/stats/:code         - Get stats (Auth)")
 	fmt.Println("  ✓ GET  /api/stats/:code/logs    - Get logs (Auth)")
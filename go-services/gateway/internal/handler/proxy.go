@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"gateway/internal/config"
+	"gateway/internal/middleware"
 )
 
 // ProxyHandler 代理处理器
@@ -43,12 +45,14 @@ func NewProxyHandler(cfg config.UpstreamConfig) (*ProxyHandler, error) {
 // HandleShortener 处理短链生成服务请求
 func (h *ProxyHandler) HandleShortener(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("→ Proxying to shortener-service: %s %s\n", r.Method, r.URL.Path)
+	setIdentityHeaders(r)
 	h.shortenerProxy.ServeHTTP(w, r)
 }
 
 // HandleRedirect 处理重定向服务请求
 func (h *ProxyHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("→ Proxying to redirect-service: %s %s\n", r.Method, r.URL.Path)
+	setIdentityHeaders(r)
 	h.redirectProxy.ServeHTTP(w, r)
 }
 
@@ -56,9 +60,24 @@ func (h *ProxyHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
 func (h *ProxyHandler) HandleShortCode(w http.ResponseWriter, r *http.Request) {
 	// 短链码在URL路径中，直接转发
 	fmt.Printf("→ Redirect short code: %s\n", r.URL.Path)
+	setIdentityHeaders(r)
 	h.redirectProxy.ServeHTTP(w, r)
 }
 
+// setIdentityHeaders 设置可信身份请求头
+// 先删除客户端自带的同名请求头，防止伪造身份，再写入鉴权中间件解析出的用户信息
+func setIdentityHeaders(r *http.Request) {
+	r.Header.Del(middleware.UserIDHeader)
+	r.Header.Del(middleware.UsernameHeader)
+
+	userID := middleware.GetUserID(r.Context())
+	if userID == 0 {
+		return
+	}
+	r.Header.Set(middleware.UserIDHeader, strconv.FormatUint(userID, 10))
+	r.Header.Set(middleware.UsernameHeader, middleware.GetUsername(r.Context()))
+}
+
 // errorHandler 代理错误处理器
 func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
 	fmt.Printf("❌ Proxy error: %v\n", err)
@@ -19,6 +19,13 @@ const (
 	UsernameKey contextKey = "username"
 )
 
+const (
+	// UserIDHeader 转发给上游服务的可信用户ID请求头
+	UserIDHeader = "X-User-ID"
+	// UsernameHeader 转发给上游服务的可信用户名请求头
+	UsernameHeader = "X-Username"
+)
+
 // AuthMiddleware JWT鉴权中间件
 type AuthMiddleware struct {
 	jwtService *service.JWTService
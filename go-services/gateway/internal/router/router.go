@@ -58,8 +58,15 @@ func (router *Router) handleAPI(w http.ResponseWriter, r *http.Request) {
 
 	// 路由到 shortener-service
 	if strings.HasPrefix(path, "/api/shorten") ||
+		path == "/api/links" ||
 		strings.HasPrefix(path, "/api/links/") ||
-		strings.HasPrefix(path, "/api/batch/") {
+		strings.HasPrefix(path, "/api/batch/") ||
+		strings.HasPrefix(path, "/api/import/") ||
+		strings.HasPrefix(path, "/api/export/") ||
+		path == "/api/campaigns" ||
+		strings.HasPrefix(path, "/api/campaigns/") ||
+		path == "/api/tags" ||
+		strings.HasPrefix(path, "/api/tags/") {
 		router.proxyHandler.HandleShortener(w, r)
 		return
 	}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -25,22 +30,49 @@ const (
 	serverPort   = ":8002"
 	kafkaBrokers = "localhost:9092"
 	kafkaTopic   = "visit-events"
+
+	// 进程内热点短链缓存，变更通过Redis发布订阅即时失效，过期时间兜底漏掉的通知
+	localCacheSize = 10000
+	localCacheTTL  = 30 * time.Second
+
+	// 访问计数在内存中累加，按该间隔批量写入Redis
+	visitFlushInterval = time.Second
+
+	// 短链尚未到生效时间时的响应：notStartedRedirectURL 非空时跳转到该地址（附带 code 和 start_at 参数），
+	// 否则以 notStartedStatus 返回提示页面，并通过 Retry-After 告知剩余秒数
+	notStartedRedirectURL = ""
+	notStartedStatus      = http.StatusForbidden
+
+	// 密码保护短链：校验通过的cookie签名密钥（多实例部署时必须一致，为空时每次启动随机生成），
+	// cookie有效期，以及每个IP在窗口期内允许的密码错误次数；
+	// 对外使用HTTPS时开启 passwordCookieSecure（TLS在网关终止，本服务无法从连接判断）
+	passwordCookieSecret  = ""
+	passwordCookieSecure  = false
+	passwordCookieTTL     = 10 * time.Minute
+	passwordMaxFailures   = 5
+	passwordFailureWindow = 15 * time.Minute
 )
 
+// notFoundPlaceholder 负缓存占位值，与 shortener-service 保持一致
+const notFoundPlaceholder = "-"
+
+// errLinkUnavailable 缓存明确表明短链不可用（不存在、已删除、禁用或过期），无需回源
+var errLinkUnavailable = errors.New("link is unavailable")
+
+// errLinkNotStarted 短链尚未到生效时间，返回“尚未开放”响应而不是404
+var errLinkNotStarted = fmt.Errorf("link is not yet available: %w", errLinkUnavailable)
+
 type RedirectService struct {
 	redisClient   *redis.Client
+	localCache    *service.LinkCache
+	visitCounter  *service.VisitCounter
+	visitQuota    *service.VisitQuota
+	passwordGuard *service.PasswordGuard
 	visitRepo     repo.VisitLogRepo
 	kafkaProducer *producer.KafkaProducer
 	shortenerURL  string
 }
 
-type ShortLink struct {
-	ShortCode   string     `json:"short_code"`
-	OriginalURL string     `json:"original_url"`
-	Status      int8       `json:"status"`
-	ExpireAt    *time.Time `json:"expire_at,omitempty"`
-}
-
 func main() {
 	log.Println("🚀 Redirect Service Starting...")
 
@@ -74,16 +106,39 @@ func main() {
 		defer kafkaProducer.Close()
 	}
 
+	// 初始化本地缓存并订阅短链变更通知
+	localCache := service.NewLinkCache(localCacheSize, localCacheTTL)
+	go localCache.Listen(ctx, redisClient)
+
+	// 初始化访问计数写回缓冲
+	visitCounter := service.NewVisitCounter(redisClient, visitFlushInterval)
+	visitCounter.Start()
+
+	// 初始化密码保护短链校验
+	secret := []byte(passwordCookieSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("❌ Failed to generate password cookie secret: %v", err)
+		}
+		log.Println("⚠️  Password cookie secret not set, using a random one; cookies won't work across instances or restarts")
+	}
+	passwordGuard := service.NewPasswordGuard(redisClient, secret, passwordCookieTTL, passwordMaxFailures, passwordFailureWindow, passwordCookieSecure)
+
 	// 创建服务实例
 	svc := &RedirectService{
 		redisClient:   redisClient,
+		localCache:    localCache,
+		visitCounter:  visitCounter,
+		visitQuota:    service.NewVisitQuota(redisClient),
+		passwordGuard: passwordGuard,
 		visitRepo:     visitRepo,
 		kafkaProducer: kafkaProducer,
 		shortenerURL:  shortenerURL,
 	}
 
 	// 创建统计处理器
-	statsHandler := handler.NewStatsHandler(visitRepo)
+	statsHandler := handler.NewStatsHandler(visitRepo, service.NewOwnerChecker(shortenerURL))
 
 	// 注册路由
 	http.HandleFunc("/api/stats/", func(w http.ResponseWriter, r *http.Request) {
@@ -100,8 +155,26 @@ func main() {
 	})
 	http.HandleFunc("/", svc.handleRedirect)
 
-	log.Printf("🌐 Redirect service listening on %s\n", serverPort)
-	log.Fatal(http.ListenAndServe(serverPort, nil))
+	server := &http.Server{Addr: serverPort}
+	go func() {
+		log.Printf("🌐 Redirect service listening on %s\n", serverPort)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("❌ Server error: %v", err)
+		}
+	}()
+
+	// 等待退出信号，停止接收请求后写入剩余的访问计数
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("🛑 Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️  Server shutdown error: %v", err)
+	}
+	visitCounter.Stop()
 }
 
 func (s *RedirectService) handleRedirect(w http.ResponseWriter, r *http.Request) {
@@ -114,89 +187,138 @@ func (s *RedirectService) handleRedirect(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 
 	// 先从Redis缓存查询
-	originalURL, err := s.getFromCache(ctx, shortCode)
-	if err == nil && originalURL != "" {
-		// 异步记录访问日志
-		go s.logVisit(shortCode, r)
-		http.Redirect(w, r, originalURL, http.StatusFound)
+	link, err := s.getFromCache(ctx, shortCode)
+	if err != nil && !errors.Is(err, errLinkUnavailable) {
+		// 缓存未命中，调用shortener服务API
+		link, err = s.getFromAPI(ctx, shortCode)
+		if err != nil && !errors.Is(err, errLinkUnavailable) {
+			log.Printf("Failed to get original URL: %v", err)
+		}
+	}
+	if errors.Is(err, errLinkNotStarted) {
+		writeNotStarted(w, r, link)
 		return
 	}
-
-	// 缓存未命中，调用shortener服务API
-	originalURL, err = s.getFromAPI(ctx, shortCode)
 	if err != nil {
-		log.Printf("Failed to get original URL: %v", err)
 		http.Error(w, "Short link not found", http.StatusNotFound)
 		return
 	}
 
+	// 密码保护的短链在校验通过前显示密码表单
+	if link.Protected() {
+		if link, err = s.loadPasswordHash(ctx, link); err != nil {
+			log.Printf("Failed to load password hash for %s: %v", shortCode, err)
+			http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !s.passwordGuard.Verified(r, link) {
+			s.handlePassword(w, r, link)
+			return
+		}
+	}
+
+	// 限制访问次数的短链接先占用额度，额度检查失败时不放行
+	ok, err := s.visitQuota.Consume(ctx, link)
+	if err != nil {
+		log.Printf("Failed to consume visit quota for %s: %v", shortCode, err)
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		http.Error(w, "Short link is no longer available", http.StatusGone)
+		return
+	}
+
+	// 访问计数在处理请求时同步累加，服务停止时 Shutdown 等待请求处理完成后再写入剩余计数
+	s.visitCounter.Incr(link.ShortCode)
+
 	// 异步记录访问日志
-	go s.logVisit(shortCode, r)
+	go s.logVisit(link, r)
 
 	// 重定向
-	http.Redirect(w, r, originalURL, http.StatusFound)
+	http.Redirect(w, r, link.OriginalURL, http.StatusFound)
 }
 
-func (s *RedirectService) getFromCache(ctx context.Context, code string) (string, error) {
+func (s *RedirectService) getFromCache(ctx context.Context, code string) (*model.ShortLink, error) {
+	// 先查进程内缓存
+	if link, ok := s.localCache.Get(code); ok {
+		return link, checkAvailable(link)
+	}
+
+	generation := s.localCache.Generation()
+
 	key := "short:code:" + code
 	data, err := s.redisClient.Get(ctx, key).Bytes()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var link ShortLink
+	if string(data) == notFoundPlaceholder {
+		return nil, errLinkUnavailable
+	}
+
+	var link model.ShortLink
 	if err := json.Unmarshal(data, &link); err != nil {
-		return "", err
+		return nil, err
 	}
+	s.localCache.Add(code, &link, generation)
 
+	return &link, checkAvailable(&link)
+}
+
+// checkAvailable 检查短链状态和生效、过期时间，访问次数上限在重定向前单独检查
+func checkAvailable(link *model.ShortLink) error {
 	if link.Status != 1 {
-		return "", fmt.Errorf("link is inactive")
+		return fmt.Errorf("link is inactive: %w", errLinkUnavailable)
+	}
+
+	if link.StartAt != nil && time.Now().Before(*link.StartAt) {
+		return errLinkNotStarted
 	}
 
 	if link.ExpireAt != nil && time.Now().After(*link.ExpireAt) {
-		return "", fmt.Errorf("link is expired")
+		return fmt.Errorf("link is expired: %w", errLinkUnavailable)
 	}
 
-	return link.OriginalURL, nil
+	return nil
 }
 
-func (s *RedirectService) getFromAPI(ctx context.Context, code string) (string, error) {
+func (s *RedirectService) getFromAPI(ctx context.Context, code string) (*model.ShortLink, error) {
 	url := fmt.Sprintf("%s/api/links/%s", s.shortenerURL, code)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("API returned status: %d", resp.StatusCode)
 	}
 
 	var result struct {
-		Code int `json:"code"`
-		Data struct {
-			OriginalURL string `json:"original_url"`
-		} `json:"data"`
+		Code int             `json:"code"`
+		Data model.ShortLink `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if result.Code != 0 {
-		return "", fmt.Errorf("API returned error code: %d", result.Code)
+		return nil, fmt.Errorf("API returned error code: %d", result.Code)
 	}
 
-	return result.Data.OriginalURL, nil
+	return &result.Data, checkAvailable(&result.Data)
 }
 
-func (s *RedirectService) logVisit(shortCode string, r *http.Request) {
+func (s *RedirectService) logVisit(link *model.ShortLink, r *http.Request) {
+	shortCode := link.ShortCode
 	visitInfo := service.ParseRequest(r)
 
 	// 1. 保存到数据库
@@ -228,13 +350,19 @@ func (s *RedirectService) logVisit(shortCode string, r *http.Request) {
 			OS:         visitInfo.OS,
 			Timestamp:  time.Now().Unix(),
 		}
+		if link.UserID != nil {
+			event.UserID = *link.UserID
+		}
+		if link.UTM != nil {
+			event.UTMSource = link.UTM.Source
+			event.UTMMedium = link.UTM.Medium
+			event.UTMCampaign = link.UTM.Campaign
+			event.UTMTerm = link.UTM.Term
+			event.UTMContent = link.UTM.Content
+		}
 
 		if err := s.kafkaProducer.SendVisitEvent(event); err != nil {
 			log.Printf("⚠️  Failed to send event to Kafka: %v", err)
 		}
 	}
-
-	// 3. 增加Redis中的访问计数
-	countKey := "visit:count:" + shortCode
-	s.redisClient.Incr(ctx, countKey)
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"redirect-service/internal/model"
+)
+
+// notStartedPage 短链尚未生效时的提示页面
+var notStartedPage = template.Must(template.New("not_started").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>链接尚未开放</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 80px;">
+<h2>链接尚未开放</h2>
+<p>该链接将于 <time datetime="{{.StartAtISO}}">{{.StartAt}}</time> 开放访问，请届时再来。</p>
+</body>
+</html>
+`))
+
+// writeNotStarted 输出“尚未开放”响应
+func writeNotStarted(w http.ResponseWriter, r *http.Request, link *model.ShortLink) {
+	if notStartedRedirectURL != "" {
+		target, err := url.Parse(notStartedRedirectURL)
+		if err == nil {
+			q := target.Query()
+			q.Set("code", link.ShortCode)
+			q.Set("start_at", link.StartAt.Format(time.RFC3339))
+			target.RawQuery = q.Encode()
+			http.Redirect(w, r, target.String(), http.StatusFound)
+			return
+		}
+		log.Printf("Invalid not-started redirect url %q: %v", notStartedRedirectURL, err)
+	}
+
+	// 提示页面不能被缓存，否则生效后仍会显示
+	wait := math.Ceil(time.Until(*link.StartAt).Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(wait, 1))))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(notStartedStatus)
+	_ = notStartedPage.Execute(w, map[string]string{
+		"StartAt":    link.StartAt.Local().Format("2006-01-02 15:04:05"),
+		"StartAtISO": link.StartAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"redirect-service/internal/model"
+	"redirect-service/internal/service"
+)
+
+// 密码表单请求体大小上限
+const maxPasswordFormSize = 4 << 10
+
+// passwordPage 密码保护短链的密码表单
+var passwordPage = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>需要密码</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 80px;">
+<h2>该链接需要密码才能访问</h2>
+{{if .Error}}<p style="color: #c00;">{{.Error}}</p>{{end}}
+<form method="post" action="/{{.Code}}">
+<input type="password" name="password" autofocus required autocomplete="current-password">
+<button type="submit">访问</button>
+</form>
+</body>
+</html>
+`))
+
+// handlePassword 显示密码表单，提交后校验密码，通过时下发cookie并跳回短链
+func (s *RedirectService) handlePassword(w http.ResponseWriter, r *http.Request, link *model.ShortLink) {
+	if r.Method != http.MethodPost {
+		writePasswordPage(w, http.StatusOK, link, "")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	ip := service.ParseRequest(r).IP
+	ok, wait, err := s.passwordGuard.Check(r.Context(), ip, link, r.PostFormValue("password"))
+	if err != nil {
+		log.Printf("Failed to check password attempts for %s: %v", ip, err)
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(wait.Seconds(), 1))))
+		writePasswordPage(w, http.StatusTooManyRequests, link, "密码错误次数过多，请稍后再试")
+		return
+	}
+	if !ok {
+		writePasswordPage(w, http.StatusUnauthorized, link, "密码错误")
+		return
+	}
+
+	s.passwordGuard.IssueCookie(w, r, link)
+	http.Redirect(w, r, "/"+link.ShortCode, http.StatusSeeOther)
+}
+
+// loadPasswordHash 补全密码哈希：回源接口不返回哈希，回源后 shortener-service 已写入缓存，从缓存重新读取
+// 校验cookie和密码都依赖哈希，哈希缺失时返回错误
+func (s *RedirectService) loadPasswordHash(ctx context.Context, link *model.ShortLink) (*model.ShortLink, error) {
+	if link.PasswordHash != "" {
+		return link, nil
+	}
+	cached, err := s.getFromCache(ctx, link.ShortCode)
+	if err != nil {
+		return nil, err
+	}
+	if cached.PasswordHash == "" {
+		return nil, errors.New("password hash missing from cache")
+	}
+	return cached, nil
+}
+
+// writePasswordPage 输出密码表单
+func writePasswordPage(w http.ResponseWriter, status int, link *model.ShortLink, message string) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = passwordPage.Execute(w, map[string]string{
+		"Code":  link.ShortCode,
+		"Error": message,
+	})
+}
@@ -3,22 +3,26 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"redirect-service/internal/repo"
+	"redirect-service/internal/service"
 )
 
 // StatsHandler 统计处理器
 type StatsHandler struct {
-	visitRepo repo.VisitLogRepo
+	visitRepo    repo.VisitLogRepo
+	ownerChecker *service.OwnerChecker
 }
 
 // NewStatsHandler 创建统计处理器
-func NewStatsHandler(visitRepo repo.VisitLogRepo) *StatsHandler {
+func NewStatsHandler(visitRepo repo.VisitLogRepo, ownerChecker *service.OwnerChecker) *StatsHandler {
 	return &StatsHandler{
-		visitRepo: visitRepo,
+		visitRepo:    visitRepo,
+		ownerChecker: ownerChecker,
 	}
 }
 
@@ -35,6 +39,10 @@ func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	if !h.authorize(w, r, shortCode) {
+		return
+	}
+
 	stats, err := h.visitRepo.GetStats(ctx, shortCode)
 	if err != nil {
 		http.Error(w, "Failed to get stats: "+err.Error(), http.StatusInternalServerError)
@@ -69,6 +77,10 @@ func (h *StatsHandler) GetRecentLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if !h.authorize(w, r, shortCode) {
+		return
+	}
+
 	ctx := context.Background()
 	logs, err := h.visitRepo.GetRecentLogs(ctx, shortCode, limit)
 	if err != nil {
@@ -83,3 +95,22 @@ func (h *StatsHandler) GetRecentLogs(w http.ResponseWriter, r *http.Request) {
 		"data":    logs,
 	})
 }
+
+// authorize 校验调用方是否有权查看该短链的统计
+// 带有 X-User-ID 的请求来自网关，只允许查看自己的短链；越权统一返回404
+func (h *StatsHandler) authorize(w http.ResponseWriter, r *http.Request, shortCode string) bool {
+	userID := r.Header.Get(service.UserIDHeader)
+	if userID == "" {
+		return true
+	}
+
+	if err := h.ownerChecker.Check(r.Context(), shortCode, userID); err != nil {
+		if errors.Is(err, service.ErrNotOwner) {
+			http.Error(w, "Short link not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to verify ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
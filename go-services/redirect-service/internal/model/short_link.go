@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// ShortLink 短链接缓存数据，字段与 shortener-service 写入 short:code: 的JSON保持一致
+type ShortLink struct {
+	ShortCode         string     `json:"short_code"`
+	OriginalURL       string     `json:"original_url"`
+	Status            int8       `json:"status"`
+	StartAt           *time.Time `json:"start_at,omitempty"`
+	ExpireAt          *time.Time `json:"expire_at,omitempty"`
+	VisitCount        uint64     `json:"visit_count"`                  // 缓存写入时的访问次数，仅用于初始化访问额度
+	MaxVisits         *uint64    `json:"max_visits,omitempty"`         // 访问次数上限，为空表示不限
+	PasswordHash      string     `json:"password_hash,omitempty"`      // 访问密码的bcrypt哈希，只存在于缓存中
+	PasswordProtected bool       `json:"password_protected,omitempty"` // 回源接口返回的是否设置了密码，接口不返回哈希
+	UTM               *UTM       `json:"utm,omitempty"`                // 目标地址中的UTM参数，随访问事件发送给统计服务
+	UserID            *uint64    `json:"user_id,omitempty"`            // 创建者，随访问事件发送给统计服务
+}
+
+// UTM 广告追踪参数
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Protected 是否需要密码才能访问
+func (l *ShortLink) Protected() bool {
+	return l.PasswordHash != "" || l.PasswordProtected
+}
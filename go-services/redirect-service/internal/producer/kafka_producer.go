@@ -10,14 +10,20 @@ import (
 
 // VisitEvent 访问事件
 type VisitEvent struct {
-	ShortCode  string `json:"short_code"`
-	IP         string `json:"ip"`
-	UserAgent  string `json:"user_agent"`
-	Referer    string `json:"referer"`
-	DeviceType string `json:"device_type"`
-	Browser    string `json:"browser"`
-	OS         string `json:"os"`
-	Timestamp  int64  `json:"timestamp"` // Unix timestamp
+	ShortCode   string `json:"short_code"`
+	UserID      uint64 `json:"user_id,omitempty"` // 短链创建者，匿名创建时为0
+	IP          string `json:"ip"`
+	UserAgent   string `json:"user_agent"`
+	Referer     string `json:"referer"`
+	DeviceType  string `json:"device_type"`
+	Browser     string `json:"browser"`
+	OS          string `json:"os"`
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	Timestamp   int64  `json:"timestamp"` // Unix timestamp
 }
 
 // KafkaProducer Kafka生产者
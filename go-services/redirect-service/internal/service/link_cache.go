@@ -0,0 +1,147 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"redirect-service/internal/model"
+)
+
+// LinkChangedChannel 短链变更通知频道，与 shortener-service 保持一致，消息内容为短链码
+const LinkChangedChannel = "short:link:changed"
+
+// linkCacheEntry 本地缓存条目
+type linkCacheEntry struct {
+	code      string
+	link      *model.ShortLink
+	expiresAt time.Time
+}
+
+// LinkCache 进程内热点短链LRU缓存
+// 位于Redis缓存之前，容量和过期时间有上限；短链变更时通过Redis发布订阅失效，
+// 订阅断开重连期间可能丢失通知，因此重连时清空整个缓存
+type LinkCache struct {
+	mu         sync.Mutex
+	capacity   int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	generation uint64 // 每次失效递增，用于丢弃失效前读取的旧数据
+}
+
+// NewLinkCache 创建本地缓存
+func NewLinkCache(capacity int, ttl time.Duration) *LinkCache {
+	return &LinkCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 读取缓存，返回的短链接只读
+func (c *LinkCache) Get(code string) (*model.ShortLink, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[code]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*linkCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.link, true
+}
+
+// Generation 当前失效代数，读取Redis前获取，写入本地缓存时传回
+func (c *LinkCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Add 写入缓存，读取期间发生过失效时放弃写入，避免缓存被旧数据覆盖
+func (c *LinkCache) Add(code string, link *model.ShortLink, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation || c.capacity <= 0 {
+		return
+	}
+
+	entry := &linkCacheEntry{code: code, link: link, expiresAt: time.Now().Add(c.ttl)}
+	if elem, ok := c.items[code]; ok {
+		elem.Value = entry
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[code] = c.ll.PushFront(entry)
+	if c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove 失效单个短链码
+func (c *LinkCache) Remove(code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.items[code]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge 清空缓存
+func (c *LinkCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Listen 订阅短链变更通知并失效对应缓存，直到ctx取消
+func (c *LinkCache) Listen(ctx context.Context, client *redis.Client) {
+	pubsub := client.Subscribe(ctx, LinkChangedChannel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// 连接断开期间的通知已丢失，清空后等待自动重连
+			c.Purge()
+			log.Printf("⚠️  Link change subscription error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// (重新)订阅成功，之前可能漏掉了通知
+			c.Purge()
+		case *redis.Message:
+			c.Remove(m.Payload)
+		}
+	}
+}
+
+// removeElement 移除条目，调用方需持有锁
+func (c *LinkCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*linkCacheEntry).code)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// UserIDHeader 网关转发的可信用户ID请求头
+const UserIDHeader = "X-User-ID"
+
+// ErrNotOwner 短链不存在或不属于调用方
+var ErrNotOwner = errors.New("short link not found or not owned by caller")
+
+// OwnerChecker 短链归属校验
+// 通过 shortener-service 的详情接口校验，该接口会按 X-User-ID 做归属过滤
+type OwnerChecker struct {
+	shortenerURL string
+	client       *http.Client
+}
+
+// NewOwnerChecker 创建归属校验器
+func NewOwnerChecker(shortenerURL string) *OwnerChecker {
+	return &OwnerChecker{
+		shortenerURL: shortenerURL,
+		client:       &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Check 校验短链是否属于指定用户
+// 只有 shortener-service 返回404时才视为不属于调用方，其他异常状态作为错误返回，避免服务故障被当成短链不存在
+func (c *OwnerChecker) Check(ctx context.Context, shortCode, userID string) error {
+	detailURL := fmt.Sprintf("%s/api/links/%s", c.shortenerURL, url.PathEscape(shortCode))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", detailURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(UserIDHeader, userID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotOwner
+	default:
+		return fmt.Errorf("shortener-service returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Code int `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return ErrNotOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOwnerCheckerCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantErr    bool
+		wantNotOwn bool
+	}{
+		{name: "owned", status: http.StatusOK, body: `{"code":0}`},
+		{name: "not found", status: http.StatusNotFound, body: `{"code":1302}`, wantErr: true, wantNotOwn: true},
+		{name: "upstream unavailable", status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			err := NewOwnerChecker(srv.URL).Check(context.Background(), "abc", "42")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNotOwner) != tt.wantNotOwn {
+				t.Errorf("Check() err = %v, want ErrNotOwner = %v", err, tt.wantNotOwn)
+			}
+		})
+	}
+}
+
+func TestOwnerCheckerEscapesShortCode(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer srv.Close()
+
+	if err := NewOwnerChecker(srv.URL).Check(context.Background(), "a/../b?x", "42"); err != nil {
+		t.Fatalf("Check() err = %v", err)
+	}
+	if want := "/api/links/a%2F..%2Fb%3Fx"; gotPath != want {
+		t.Errorf("path = %q, want %q", gotPath, want)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"net"
 	"net/http"
 	"strings"
 
@@ -64,28 +65,85 @@ func ParseRequest(r *http.Request) *VisitInfo {
 	return info
 }
 
+// trustedProxies 可信代理（网关）所在网段，只有来自这些地址的请求才读取转发头
+// X-Forwarded-For 最左侧的值由客户端任意填写，只能从右向左跳过可信代理，取第一个不可信的地址
+var trustedProxies = parseCIDRs(
+	"127.0.0.0/8",
+	"::1/128",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
 // extractIP 提取真实IP地址
 func extractIP(r *http.Request) string {
-	// 尝试从 X-Forwarded-For 获取
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip) {
+		return ip
 	}
 
-	// 尝试从 X-Real-IP 获取
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return strings.TrimSpace(xri)
+	// 从右向左跳过可信代理追加的地址
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// 无法解析的值不可信，使用已确认的上一跳
+				return ip
+			}
+			ip = hop
+			if !isTrustedProxy(hop) {
+				return ip
+			}
+		}
+		return ip
 	}
 
-	// 使用 RemoteAddr
-	ip := r.RemoteAddr
-	// 去掉端口号
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
 	}
 	return ip
 }
+
+// isHTTPS 客户端是否通过HTTPS访问：直连TLS，或可信代理通过 X-Forwarded-Proto 告知
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !isTrustedProxy(host) {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto")), "https")
+}
+
+// isTrustedProxy 地址是否属于可信代理网段
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs 解析网段列表
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xri        string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "direct client ignores forged xff", remoteAddr: "203.0.113.7:5000", xff: "1.2.3.4", want: "203.0.113.7"},
+		{name: "direct client ignores forged x-real-ip", remoteAddr: "203.0.113.7:5000", xri: "1.2.3.4", want: "203.0.113.7"},
+		{name: "gateway appended hop", remoteAddr: "10.0.0.2:4000", xff: "198.51.100.9", want: "198.51.100.9"},
+		{name: "forged leftmost entry ignored", remoteAddr: "10.0.0.2:4000", xff: "1.2.3.4, 198.51.100.9", want: "198.51.100.9"},
+		{name: "skips trusted proxy chain", remoteAddr: "127.0.0.1:4000", xff: "6.6.6.6, 198.51.100.9, 10.1.1.1, 192.168.1.5", want: "198.51.100.9"},
+		{name: "garbage hop falls back to last trusted", remoteAddr: "10.0.0.2:4000", xff: "not-an-ip", want: "10.0.0.2"},
+		{name: "all hops trusted", remoteAddr: "10.0.0.2:4000", xff: "192.168.1.1", want: "192.168.1.1"},
+		{name: "x-real-ip from proxy", remoteAddr: "10.0.0.2:4000", xri: "198.51.100.9", want: "198.51.100.9"},
+		{name: "ipv6 remote", remoteAddr: "[2001:db8::1]:443", xff: "1.2.3.4", want: "2001:db8::1"},
+		{name: "ipv6 loopback proxy", remoteAddr: "[::1]:443", xff: "2001:db8::2", want: "2001:db8::2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/abc", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xri != "" {
+				r.Header.Set("X-Real-IP", tt.xri)
+			}
+			if got := extractIP(r); got != tt.want {
+				t.Errorf("extractIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		want       bool
+	}{
+		{name: "plain direct client", remoteAddr: "203.0.113.7:5000", want: false},
+		{name: "direct client forged proto", remoteAddr: "203.0.113.7:5000", proto: "https", want: false},
+		{name: "gateway forwarded https", remoteAddr: "10.0.0.2:4000", proto: "https", want: true},
+		{name: "gateway forwarded http", remoteAddr: "10.0.0.2:4000", proto: "http", want: false},
+		{name: "gateway without proto", remoteAddr: "10.0.0.2:4000", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/abc", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if got := isHTTPS(r); got != tt.want {
+				t.Errorf("isHTTPS = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
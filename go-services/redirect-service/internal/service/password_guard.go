@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+
+	"redirect-service/internal/model"
+)
+
+const (
+	// passwordFailPrefix 按IP统计密码错误次数的key前缀
+	passwordFailPrefix = "password:fail:"
+	// passwordCookiePrefix 校验通过后下发的cookie名前缀，后接短链码
+	passwordCookiePrefix = "sl_pw_"
+)
+
+// reserveAttemptScript 原子地占用一次尝试机会：次数已达上限时不占用并返回剩余封禁时间(毫秒)，
+// 否则计数加一并返回0；窗口从第一次尝试开始计算。检查和计数在同一脚本内，并发请求不会同时通过检查
+var reserveAttemptScript = redis.NewScript(`
+	local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+	if n >= tonumber(ARGV[1]) then
+		local ttl = redis.call('PTTL', KEYS[1])
+		if ttl <= 0 then
+			redis.call('PEXPIRE', KEYS[1], ARGV[2])
+			ttl = tonumber(ARGV[2])
+		end
+		return ttl
+	end
+	n = redis.call('INCR', KEYS[1])
+	if n == 1 then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+// releaseAttemptScript 密码正确时退还占用的尝试机会
+var releaseAttemptScript = redis.NewScript(`
+	local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+	if n > 0 then
+		redis.call('DECR', KEYS[1])
+	end
+	return 0
+`)
+
+// PasswordGuard 密码保护短链的校验
+// 校验通过后下发带签名的短期cookie，签名包含密码哈希，修改密码后旧cookie立即失效；
+// 按IP限制一段时间内的错误次数，防止暴力猜测
+type PasswordGuard struct {
+	client        *redis.Client
+	secret        []byte
+	cookieTTL     time.Duration
+	maxFailures   int64
+	failureWindow time.Duration
+	secureCookie  bool // 总是标记 Secure，TLS在网关终止时本服务看不到 r.TLS
+}
+
+// NewPasswordGuard 创建密码校验，多实例部署时 secret 必须一致
+// secureCookie 为 false 时，只有直连TLS或可信代理转发 X-Forwarded-Proto: https 的请求才标记 Secure
+func NewPasswordGuard(client *redis.Client, secret []byte, cookieTTL time.Duration, maxFailures int64, failureWindow time.Duration, secureCookie bool) *PasswordGuard {
+	return &PasswordGuard{
+		client:        client,
+		secret:        secret,
+		cookieTTL:     cookieTTL,
+		maxFailures:   maxFailures,
+		failureWindow: failureWindow,
+		secureCookie:  secureCookie,
+	}
+}
+
+// Verified 请求是否带有该短链有效的校验cookie
+func (g *PasswordGuard) Verified(r *http.Request, link *model.ShortLink) bool {
+	cookie, err := r.Cookie(passwordCookiePrefix + link.ShortCode)
+	if err != nil {
+		return false
+	}
+
+	expires, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+
+	expected := g.sign(link, exp)
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
+
+// Check 校验密码，每次校验先原子地占用该IP的一次尝试机会，密码正确时退还
+// 该IP的错误次数已达上限时不校验密码，返回剩余封禁时间
+func (g *PasswordGuard) Check(ctx context.Context, ip string, link *model.ShortLink, password string) (bool, time.Duration, error) {
+	keys := []string{passwordFailPrefix + ip}
+	wait, err := reserveAttemptScript.Run(ctx, g.client, keys, g.maxFailures, g.failureWindow.Milliseconds()).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Millisecond, nil
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		return false, 0, nil
+	}
+	if err := releaseAttemptScript.Run(ctx, g.client, keys).Err(); err != nil {
+		log.Printf("Failed to release password attempt for %s: %v", ip, err)
+	}
+	return true, 0, nil
+}
+
+// IssueCookie 下发校验通过的cookie，仅对该短链路径有效
+func (g *PasswordGuard) IssueCookie(w http.ResponseWriter, r *http.Request, link *model.ShortLink) {
+	exp := time.Now().Add(g.cookieTTL).Unix()
+	http.SetCookie(w, &http.Cookie{
+		Name:     passwordCookiePrefix + link.ShortCode,
+		Value:    strconv.FormatInt(exp, 10) + "." + g.sign(link, exp),
+		Path:     "/" + link.ShortCode,
+		MaxAge:   int(g.cookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   g.secureCookie || isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign 对短链码、过期时间和密码哈希签名
+func (g *PasswordGuard) sign(link *model.ShortLink, exp int64) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(link.ShortCode))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(exp, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(link.PasswordHash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
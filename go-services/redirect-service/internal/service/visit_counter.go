@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// VisitCountPrefix Redis访问计数key前缀，由 shortener-service 定期合并到数据库
+	VisitCountPrefix = "visit:count:"
+	// VisitLastPrefix 最后访问时间(毫秒)key前缀
+	VisitLastPrefix = "visit:last:"
+	// 最后访问时间key的过期时间，合并后数据库中已有记录
+	visitLastTTL = 7 * 24 * time.Hour
+)
+
+// setVisitLastScript 只在新时间更晚时写入最后访问时间，多实例并发写回时不会回退
+var setVisitLastScript = redis.NewScript(`
+	local last = tonumber(redis.call('GET', KEYS[1]))
+	if last == nil or tonumber(ARGV[1]) > last then
+		redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	else
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	end
+	return 0
+`)
+
+// VisitCounter 访问计数写回缓冲
+// 在内存中按短链码累加访问次数，定期用一次pipeline批量 INCRBY 到Redis并记录较晚的最后访问时间，停止时写入剩余计数
+type VisitCounter struct {
+	client   *redis.Client
+	interval time.Duration
+
+	mu     sync.Mutex
+	deltas map[string]int64
+	lasts  map[string]int64 // 最后访问时间(毫秒)
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewVisitCounter 创建访问计数写回缓冲
+func NewVisitCounter(client *redis.Client, interval time.Duration) *VisitCounter {
+	return &VisitCounter{
+		client:   client,
+		interval: interval,
+		deltas:   make(map[string]int64),
+		lasts:    make(map[string]int64),
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Incr 记录一次访问
+func (c *VisitCounter) Incr(code string) {
+	c.mu.Lock()
+	c.deltas[code]++
+	c.lasts[code] = time.Now().UnixMilli()
+	c.mu.Unlock()
+}
+
+// Start 启动定期写入
+func (c *VisitCounter) Start() {
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.flush()
+			case <-c.stopCh:
+				c.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期写入并写入剩余计数
+func (c *VisitCounter) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+	<-c.done
+}
+
+// flush 批量写入累计的访问次数，失败时合并回缓冲等待下次写入
+func (c *VisitCounter) flush() {
+	c.mu.Lock()
+	deltas, lasts := c.deltas, c.lasts
+	c.deltas = make(map[string]int64)
+	c.lasts = make(map[string]int64)
+	c.mu.Unlock()
+
+	if len(deltas) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := c.client.Pipeline()
+	for code, delta := range deltas {
+		pipe.IncrBy(ctx, VisitCountPrefix+code, delta)
+		// pipeline中无法处理 NOSCRIPT 重试，直接用 EVAL 发送脚本
+		setVisitLastScript.Eval(ctx, pipe, []string{VisitLastPrefix + code}, lasts[code], visitLastTTL.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		// pipeline部分失败时无法区分已写入的命令，整体重试可能少量重复计数
+		log.Printf("⚠️  Failed to flush visit counts: %v", err)
+
+		c.mu.Lock()
+		for code, delta := range deltas {
+			c.deltas[code] += delta
+			if lasts[code] > c.lasts[code] {
+				c.lasts[code] = lasts[code]
+			}
+		}
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"redirect-service/internal/model"
+)
+
+const (
+	// VisitQuotaPrefix 已消耗的访问额度key前缀，与 shortener-service 保持一致
+	VisitQuotaPrefix = "visit:quota:"
+	// VisitExhaustedKey 额度已用尽的短链码集合，由 shortener-service 在数据库中禁用
+	VisitExhaustedKey = "visit:exhausted"
+)
+
+// consumeVisitQuotaScript 原子占用一次访问额度，额度只在这里占用，shortener-service 只负责重置和删除计数
+// 计数不存在时（首次访问或被淘汰）以 ARGV[2] 初始化，ARGV[4] 大于0时随短链接在该时间(Unix秒)过期；
+// 占用后恰好用尽时加入待禁用集合
+var consumeVisitQuotaScript = redis.NewScript(`
+	local seeded = redis.call('EXISTS', KEYS[1]) == 0
+	if seeded then
+		redis.call('SET', KEYS[1], ARGV[2])
+	end
+	local result = 0
+	if tonumber(redis.call('GET', KEYS[1])) < tonumber(ARGV[1]) then
+		result = 1
+		if redis.call('INCR', KEYS[1]) >= tonumber(ARGV[1]) then
+			redis.call('SADD', KEYS[2], ARGV[3])
+		end
+	end
+	local expireAt = tonumber(ARGV[4])
+	if seeded and expireAt > 0 then
+		redis.call('EXPIREAT', KEYS[1], expireAt)
+	end
+	return result
+`)
+
+// VisitQuota 访问次数上限检查
+// 重定向前在Redis中原子占用一次额度，并发访问不会超出上限（阅后即焚链接只能成功访问一次）
+type VisitQuota struct {
+	client *redis.Client
+}
+
+// NewVisitQuota 创建访问次数上限检查
+func NewVisitQuota(client *redis.Client) *VisitQuota {
+	return &VisitQuota{client: client}
+}
+
+// Consume 占用一次访问额度，额度已用尽时返回false；未设置上限的短链接直接放行
+func (q *VisitQuota) Consume(ctx context.Context, link *model.ShortLink) (bool, error) {
+	if link.MaxVisits == nil {
+		return true, nil
+	}
+
+	var expireAt int64
+	if link.ExpireAt != nil {
+		expireAt = link.ExpireAt.Unix()
+	}
+
+	keys := []string{VisitQuotaPrefix + link.ShortCode, VisitExhaustedKey}
+	n, err := consumeVisitQuotaScript.Run(ctx, q.client, keys, *link.MaxVisits, link.VisitCount, link.ShortCode, expireAt).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -3,19 +3,25 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"time"
 
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/rest"
 
 	"shortener-service/internal/config"
 	"shortener-service/internal/handler"
+	"shortener-service/internal/middleware"
 	"shortener-service/internal/repo"
 	"shortener-service/internal/service"
 )
 
 var configFile = flag.String("f", "internal/config/config.yaml", "the config file")
 
+// decodeCode 调试用：将生成的短链码解码为ID后退出
+var decodeCode = flag.String("decode", "", "decode a generated short code to its id and exit")
+
 func main() {
 	flag.Parse()
 
@@ -23,6 +29,11 @@ func main() {
 	var c config.Config
 	conf.MustLoad(*configFile, &c)
 
+	if *decodeCode != "" {
+		decodeShortCode(c, *decodeCode)
+		return
+	}
+
 	// 初始化数据库Repository
 	dbRepo, err := repo.NewShortLinkRepo(c.Mysql.DataSource)
 	if err != nil {
@@ -35,37 +46,149 @@ func main() {
 		log.Fatalf("Failed to init redis repo: %v", err)
 	}
 
+	// 号段模式需要号段分配表
+	var segmentRepo repo.SegmentRepo
+	if c.ShortUrl.CodeStrategy == service.IDGenSegment {
+		segmentRepo, err = repo.NewSegmentRepo(c.Mysql.DataSource)
+		if err != nil {
+			log.Fatalf("Failed to init segment repo: %v", err)
+		}
+	}
+
 	// 初始化ID生成器
-	idGen, err := service.NewSnowflakeIDGen(c.Snowflake.MachineID)
+	idGen, err := service.NewIDGenerator(service.IDGenOptions{
+		Strategy:       c.ShortUrl.CodeStrategy,
+		Alphabet:       c.ShortUrl.CodeAlphabet,
+		CodeLength:     c.ShortUrl.CodeLength,
+		MachineID:      c.Snowflake.MachineID,
+		MachineLease:   c.Snowflake.MachineLease,
+		LeaseTTL:       time.Duration(c.Snowflake.LeaseTTL) * time.Second,
+		ObfuscationKey: c.ShortUrl.ObfuscationKey,
+		SegmentBizTag:  c.Segment.BizTag,
+		SegmentStep:    c.Segment.Step,
+	}, redisRepo, segmentRepo)
 	if err != nil {
 		log.Fatalf("Failed to init id generator: %v", err)
 	}
+	// 退出时释放机器ID租约等资源
+	if closer, ok := idGen.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// 初始化目标地址黑名单检查
+	var screener *service.Screener
+	if c.Screening.Enabled {
+		screener, err = service.NewScreener(
+			c.Screening.HostsFile,
+			c.Screening.HashPrefixFile,
+			c.Screening.ReloadInterval,
+			c.Screening.RetroDisable,
+			dbRepo,
+			redisRepo,
+		)
+		if err != nil {
+			log.Fatalf("Failed to init screener: %v", err)
+		}
+		screener.Start()
+		defer screener.Stop()
+	}
+
+	// 初始化自定义短链码策略
+	aliasPolicy, err := service.NewAliasPolicy(
+		c.AliasPolicy.Charset,
+		c.AliasPolicy.MinLength,
+		c.AliasPolicy.MaxLength,
+		c.AliasPolicy.ReservedWords,
+		c.AliasPolicy.ProfanityWords,
+		c.AliasPolicy.CaseFolding,
+	)
+	if err != nil {
+		log.Fatalf("Failed to init alias policy: %v", err)
+	}
+	if c.AliasPolicy.ProfanityFile != "" {
+		if err := aliasPolicy.LoadProfanityFile(c.AliasPolicy.ProfanityFile); err != nil {
+			log.Fatalf("Failed to load profanity file: %v", err)
+		}
+	}
+
+	// 初始化短链码布隆过滤器
+	var codeFilter *service.CodeFilter
+	if c.CodeFilter.Enabled {
+		codeFilter, err = service.NewCodeFilter(
+			redisRepo,
+			dbRepo,
+			c.CodeFilter.ExpectedItems,
+			c.CodeFilter.FalsePositiveRate,
+			c.CodeFilter.RebuildInterval,
+		)
+		if err != nil {
+			log.Fatalf("Failed to init code filter: %v", err)
+		}
+		codeFilter.Start()
+		defer codeFilter.Stop()
+	}
+
+	// 启动访问计数合并任务
+	visitReconciler := service.NewVisitReconciler(redisRepo, dbRepo, c.ShortUrl.VisitReconcileInterval)
+	visitReconciler.Start()
+	defer visitReconciler.Stop()
+
+	// 初始化活动和标签
+	campaignRepo, err := repo.NewCampaignRepo(c.Mysql.DataSource)
+	if err != nil {
+		log.Fatalf("Failed to init campaign repo: %v", err)
+	}
+	campaignSvc := service.NewCampaignService(campaignRepo, redisRepo)
 
 	// 初始化短链服务
 	shortenerSvc := service.NewShortenerService(
 		dbRepo,
 		redisRepo,
+		campaignRepo,
 		idGen,
+		service.NewURLNormalizer(c.URLPolicy.AllowedSchemes, c.URLPolicy.MaxLength),
+		screener,
+		aliasPolicy,
+		codeFilter,
 		c.ShortUrl.Domain,
 		c.ShortUrl.CacheTTL,
+		c.ShortUrl.NegativeCacheTTL,
 	)
 
+	// 初始化CSV批量导入任务
+	importJobRepo, err := repo.NewImportJobRepo(c.Mysql.DataSource)
+	if err != nil {
+		log.Fatalf("Failed to init import job repo: %v", err)
+	}
+	importJobSvc, err := service.NewImportJobService(importJobRepo, shortenerSvc, c.Import.Dir, c.Import.MaxRows,
+		time.Duration(c.Import.StaleAfter)*time.Second, time.Duration(c.Import.Retention)*time.Second, handler.PublicErrorMessage)
+	if err != nil {
+		log.Fatalf("Failed to init import job service: %v", err)
+	}
+	importJobSvc.Start(c.Import.Workers)
+	defer importJobSvc.Stop()
+
 	// 创建HTTP服务器
 	server := rest.MustNewServer(c.RestConf)
 	defer server.Stop()
 
+	// 解析网关转发的调用方身份
+	server.Use(middleware.NewIdentityMiddleware().Handle)
+
 	// 注册路由
-	registerHandlers(server, shortenerSvc)
+	registerHandlers(server, shortenerSvc, campaignSvc, importJobSvc, c.Import.MaxUploadSize)
 
 	fmt.Printf("Starting server at %s:%d...\n", c.Host, c.Port)
 	server.Start()
 }
 
 // registerHandlers 注册路由
-func registerHandlers(server *rest.Server, svc service.ShortenerService) {
+func registerHandlers(server *rest.Server, svc service.ShortenerService, campaigns service.CampaignService, imports service.ImportJobService, maxUploadSize int64) {
 	// 短链生成处理器
 	shortenHandler := handler.NewShortenHandler(svc)
 	batchHandler := handler.NewBatchHandler(svc)
+	jobHandler := handler.NewJobHandler(svc, imports, maxUploadSize)
+	campaignHandler := handler.NewCampaignHandler(campaigns)
 
 	// 路由组
 	server.AddRoutes(
@@ -76,18 +199,138 @@ func registerHandlers(server *rest.Server, svc service.ShortenerService) {
 				Path:    "/api/shorten",
 				Handler: shortenHandler.CreateShortLink,
 			},
+			// 查询短链接列表
+			{
+				Method:  "GET",
+				Path:    "/api/links",
+				Handler: shortenHandler.ListShortLinks,
+			},
 			// 获取短链接详情
 			{
 				Method:  "GET",
 				Path:    "/api/links/:code",
 				Handler: shortenHandler.GetShortLink,
 			},
+			// 更新短链接
+			{
+				Method:  "PATCH",
+				Path:    "/api/links/:code",
+				Handler: shortenHandler.UpdateShortLink,
+			},
+			// 删除短链接
+			{
+				Method:  "DELETE",
+				Path:    "/api/links/:code",
+				Handler: shortenHandler.DeleteShortLink,
+			},
 			// 批量创建短链接
 			{
 				Method:  "POST",
 				Path:    "/api/batch/shorten",
 				Handler: batchHandler.BatchCreateShortLinks,
 			},
+			// 查询导入任务进度
+			{
+				Method:  "GET",
+				Path:    "/api/import/jobs/:id",
+				Handler: jobHandler.GetImportJob,
+			},
+			// 活动
+			{
+				Method:  "GET",
+				Path:    "/api/campaigns",
+				Handler: campaignHandler.ListCampaigns,
+			},
+			{
+				Method:  "POST",
+				Path:    "/api/campaigns",
+				Handler: campaignHandler.CreateCampaign,
+			},
+			{
+				Method:  "GET",
+				Path:    "/api/campaigns/:id",
+				Handler: campaignHandler.GetCampaign,
+			},
+			{
+				Method:  "PATCH",
+				Path:    "/api/campaigns/:id",
+				Handler: campaignHandler.UpdateCampaign,
+			},
+			{
+				Method:  "DELETE",
+				Path:    "/api/campaigns/:id",
+				Handler: campaignHandler.DeleteCampaign,
+			},
+			// 标签
+			{
+				Method:  "GET",
+				Path:    "/api/tags",
+				Handler: campaignHandler.ListTags,
+			},
+			{
+				Method:  "POST",
+				Path:    "/api/tags",
+				Handler: campaignHandler.CreateTag,
+			},
+			{
+				Method:  "PATCH",
+				Path:    "/api/tags/:id",
+				Handler: campaignHandler.UpdateTag,
+			},
+			{
+				Method:  "DELETE",
+				Path:    "/api/tags/:id",
+				Handler: campaignHandler.DeleteTag,
+			},
+		},
+	)
+
+	// 上传导入文件：放宽请求体大小限制，上传大文件时不受默认超时限制
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  "POST",
+				Path:    "/api/import/jobs",
+				Handler: jobHandler.CreateImportJob,
+			},
+		},
+		rest.WithMaxBytes(maxUploadSize),
+		rest.WithTimeout(0),
+	)
+
+	// 下载导入结果和导出短链接：流式输出，不设超时（超时处理会缓冲整个响应）
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  "GET",
+				Path:    "/api/import/jobs/:id/result",
+				Handler: jobHandler.DownloadImportResult,
+			},
+			{
+				Method:  "GET",
+				Path:    "/api/export/links",
+				Handler: jobHandler.ExportLinks,
+			},
 		},
+		rest.WithTimeout(0),
 	)
 }
+
+// decodeShortCode 解码短链码并打印对应的ID
+func decodeShortCode(c config.Config, code string) {
+	alphabet := c.ShortUrl.CodeAlphabet
+	if alphabet == "" {
+		alphabet = service.DefaultAlphabet
+	}
+
+	encoder, err := service.NewCodeEncoder(alphabet, c.ShortUrl.CodeLength, c.ShortUrl.ObfuscationKey)
+	if err != nil {
+		log.Fatalf("Failed to init code encoder: %v", err)
+	}
+
+	id, err := encoder.Decode(code)
+	if err != nil {
+		log.Fatalf("Failed to decode %s: %v", code, err)
+	}
+	fmt.Printf("%s => %d\n", code, id)
+}
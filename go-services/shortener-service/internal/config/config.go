@@ -7,7 +7,13 @@ type Config struct {
 	Mysql         MysqlConfig
 	Redis         RedisConfig
 	Snowflake     SnowflakeConfig
+	Segment       SegmentConfig
 	ShortUrl      ShortUrlConfig
+	URLPolicy     URLPolicyConfig
+	Screening     ScreeningConfig
+	AliasPolicy   AliasPolicyConfig
+	CodeFilter    CodeFilterConfig
+	Import        ImportConfig
 	// 删除 Log LogConfig 这一行
 }
 
@@ -23,13 +29,64 @@ type RedisConfig struct {
 }
 
 type SnowflakeConfig struct {
-	MachineID int64
+	MachineID    int64 `json:",optional"`   // 静态机器ID，启用租约时忽略
+	MachineLease bool  `json:",optional"`   // 从Redis自动租用机器ID，多实例部署时无需手动分配
+	LeaseTTL     int   `json:",default=30"` // 机器ID租约有效期(秒)，每1/3有效期续约一次
+}
+
+type SegmentConfig struct {
+	BizTag string `json:",default=short_link"` // 号段表中的业务标识
+	Step   int    `json:",default=1000"`       // 每次租用的ID数量
 }
 
 type ShortUrlConfig struct {
-	Domain     string
-	CodeLength int
-	CacheTTL   int
+	Domain                 string
+	CodeLength             int
+	CodeStrategy           string `json:",default=snowflake,options=snowflake|random|counter|segment"` // 短链码生成策略
+	CodeAlphabet           string `json:",optional"`                                                   // 短链码字符集，默认Base62
+	ObfuscationKey         string `json:",optional"`                                                   // 非空时对ID做带密钥的置换，短链码不可枚举
+	CacheTTL               int
+	NegativeCacheTTL       int `json:",default=60"` // 负缓存过期时间(秒)
+	VisitReconcileInterval int `json:",default=10"` // 合并 redirect-service 在Redis中的访问计数的间隔(秒)
+}
+
+type URLPolicyConfig struct {
+	AllowedSchemes []string `json:",default=[http,https]"` // 允许的协议
+	MaxLength      int      `json:",default=2048"`         // 与 original_url 列长度一致
+}
+
+type ScreeningConfig struct {
+	Enabled        bool
+	HostsFile      string `json:",optional"`     // 主机名/通配域名黑名单文件
+	HashPrefixFile string `json:",optional"`     // SHA-256 哈希前缀黑名单文件
+	ReloadInterval int    `json:",default=60"`   // 检查名单文件变化的间隔(秒)
+	RetroDisable   bool   `json:",default=true"` // 启动时和新增条目时禁用已存在的匹配短链
+}
+
+type AliasPolicyConfig struct {
+	Charset        string   `json:",default=0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"`
+	MinLength      int      `json:",default=4"`
+	MaxLength      int      `json:",default=20"`                              // 不能超过 short_code 列长度
+	ReservedWords  []string `json:",optional"`                                // 保留字，不区分大小写
+	ProfanityWords []string `json:",optional"`                                // 敏感词，子串匹配
+	ProfanityFile  string   `json:",optional"`                                // 敏感词文件，每行一个
+	CaseFolding    string   `json:",default=preserve,options=preserve|lower"` // preserve-区分大小写 lower-统一转小写
+}
+
+type CodeFilterConfig struct {
+	Enabled           bool
+	ExpectedItems     int     `json:",default=1000000"` // 预期短链数量，超出后误判率上升
+	FalsePositiveRate float64 `json:",default=0.001"`   // 目标误判率
+	RebuildInterval   int     `json:",default=3600"`    // 从数据库全量重建的间隔(秒)，0表示仅启动时构建
+}
+
+type ImportConfig struct {
+	Dir           string `json:",default=data/imports"` // 上传文件和结果文件目录，多实例部署时需共享
+	MaxUploadSize int64  `json:",default=10485760"`     // 上传文件大小上限(字节)
+	MaxRows       int    `json:",default=10000"`        // 单个文件的最大数据行数
+	Workers       int    `json:",default=2"`            // 同时处理的导入任务数
+	StaleAfter    int    `json:",default=3600"`         // 启动时把超过该时间(秒)没有进度的未结束任务标记为失败
+	Retention     int    `json:",default=604800"`       // 结果文件保留时间(秒)，过期后删除
 }
 
 // 删除整个 LogConfig 结构体
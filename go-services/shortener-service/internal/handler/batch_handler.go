@@ -26,25 +26,42 @@ func (h *BatchHandler) BatchCreateShortLinks(w http.ResponseWriter, r *http.Requ
 
 	// 手动解析 JSON
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpx.ErrorCtx(r.Context(), w, err)
-		return
-	}
-
-	// 基本验证
-	if len(req.URLs) == 0 {
 		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
-			Code:    1,
-			Message: "urls is required",
+			Code:    CodeBadRequest,
+			Message: "invalid request body: " + err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.svc.BatchCreateShortLinks(r.Context(), req.URLs)
+	outcomes, err := h.svc.BatchCreateShortLinks(r.Context(), &req)
 	if err != nil {
-		httpx.ErrorCtx(r.Context(), w, err)
+		writeError(w, r, err)
 		return
 	}
 
+	resp := &types.BatchShortenResponse{
+		Results: make([]types.BatchItemResult, len(outcomes)),
+	}
+	for i, outcome := range outcomes {
+		result := types.BatchItemResult{Index: i}
+		if outcome.Err == nil {
+			result.Status = http.StatusOK
+			result.Message = "success"
+			result.Data = outcome.Response
+			resp.Success++
+		} else {
+			result.Status = http.StatusInternalServerError
+			result.Code = CodeInternal
+			if m, ok := lookupError(outcome.Err); ok {
+				result.Status = m.status
+				result.Code = m.code
+			}
+			result.Message = PublicErrorMessage(outcome.Err)
+			resp.Failed++
+		}
+		resp.Results[i] = result
+	}
+
 	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
 		Code:    0,
 		Message: "success",
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"shortener-service/internal/service"
+	"shortener-service/internal/types"
+)
+
+// CampaignHandler 活动和标签处理器
+type CampaignHandler struct {
+	svc service.CampaignService
+}
+
+// NewCampaignHandler 创建活动和标签处理器
+func NewCampaignHandler(svc service.CampaignService) *CampaignHandler {
+	return &CampaignHandler{svc: svc}
+}
+
+// CreateCampaign 创建活动
+func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var req types.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.ErrorCtx(r.Context(), w, err)
+		return
+	}
+
+	resp, err := h.svc.CreateCampaign(r.Context(), &req)
+	writeResult(w, r, resp, err)
+}
+
+// ListCampaigns 查询活动列表
+func (h *CampaignHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.ListCampaigns(r.Context())
+	writeResult(w, r, resp, err)
+}
+
+// GetCampaign 获取活动详情
+func (h *CampaignHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "/api/campaigns/")
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.GetCampaign(r.Context(), id)
+	writeResult(w, r, resp, err)
+}
+
+// UpdateCampaign 更新活动
+func (h *CampaignHandler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "/api/campaigns/")
+	if !ok {
+		return
+	}
+
+	var req types.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.ErrorCtx(r.Context(), w, err)
+		return
+	}
+
+	resp, err := h.svc.UpdateCampaign(r.Context(), id, &req)
+	writeResult(w, r, resp, err)
+}
+
+// DeleteCampaign 删除活动，活动下的短链接保留
+func (h *CampaignHandler) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "/api/campaigns/")
+	if !ok {
+		return
+	}
+
+	writeResult(w, r, nil, h.svc.DeleteCampaign(r.Context(), id))
+}
+
+// CreateTag 创建标签
+func (h *CampaignHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var req types.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.ErrorCtx(r.Context(), w, err)
+		return
+	}
+
+	resp, err := h.svc.CreateTag(r.Context(), &req)
+	writeResult(w, r, resp, err)
+}
+
+// ListTags 查询标签列表
+func (h *CampaignHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.ListTags(r.Context())
+	writeResult(w, r, resp, err)
+}
+
+// UpdateTag 重命名标签
+func (h *CampaignHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "/api/tags/")
+	if !ok {
+		return
+	}
+
+	var req types.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.ErrorCtx(r.Context(), w, err)
+		return
+	}
+
+	resp, err := h.svc.UpdateTag(r.Context(), id, &req)
+	writeResult(w, r, resp, err)
+}
+
+// DeleteTag 删除标签
+func (h *CampaignHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "/api/tags/")
+	if !ok {
+		return
+	}
+
+	writeResult(w, r, nil, h.svc.DeleteTag(r.Context(), id))
+}
+
+// pathID 从路径中解析数字ID，格式错误时输出错误响应并返回false
+func pathID(w http.ResponseWriter, r *http.Request, prefix string) (uint64, bool) {
+	v := strings.TrimPrefix(r.URL.Path, prefix)
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    CodeBadRequest,
+			Message: fmt.Sprintf("invalid id: %s", v),
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// writeResult 输出成功响应，err 非空时输出错误响应
+func writeResult(w http.ResponseWriter, r *http.Request, data interface{}, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	})
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"shortener-service/internal/service"
+	"shortener-service/internal/types"
+)
+
+// 导出格式
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+)
+
+// 每导出多少条刷新一次响应
+const exportFlushEvery = 100
+
+// exportHeader 导出CSV表头
+var exportHeader = []string{"short_code", "short_url", "original_url", "title", "description", "visit_count", "last_visited_at", "status", "start_at", "expire_at", "created_at", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// JobHandler 批量导入导出处理器
+type JobHandler struct {
+	svc           service.ShortenerService
+	imports       service.ImportJobService
+	maxUploadSize int64
+}
+
+// NewJobHandler 创建批量导入导出处理器
+func NewJobHandler(svc service.ShortenerService, imports service.ImportJobService, maxUploadSize int64) *JobHandler {
+	return &JobHandler{svc: svc, imports: imports, maxUploadSize: maxUploadSize}
+}
+
+// CreateImportJob 上传CSV创建导入任务
+// 支持 multipart/form-data 的 file 字段，或直接以 text/csv 作为请求体
+func (h *JobHandler) CreateImportJob(w http.ResponseWriter, r *http.Request) {
+	// 分块传输没有 Content-Length，路由上的大小限制不生效，这里再限制一次
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+
+	file, err := uploadedFile(r)
+	if err != nil {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    CodeBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.imports.CreateImportJob(r.Context(), file)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpx.WriteJsonCtx(r.Context(), w, http.StatusRequestEntityTooLarge, types.CommonResponse{
+				Code:    CodeImportTooLarge,
+				Message: fmt.Sprintf("file too large, limit is %d bytes", h.maxUploadSize),
+			})
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.WriteJsonCtx(r.Context(), w, http.StatusAccepted, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// GetImportJob 查询导入任务进度
+func (h *JobHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.imports.GetImportJob(r.Context(), jobID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// DownloadImportResult 下载导入结果CSV
+func (h *JobHandler) DownloadImportResult(w http.ResponseWriter, r *http.Request) {
+	id := jobID(r)
+	result, err := h.imports.OpenImportResult(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer result.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%s-result.csv"`, id))
+	_, _ = io.Copy(w, result)
+}
+
+// ExportLinks 流式导出短链接，format=csv|ndjson，筛选和排序参数与列表接口一致
+func (h *JobHandler) ExportLinks(w http.ResponseWriter, r *http.Request) {
+	req, err := parseListRequest(r)
+	if err != nil {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    CodeBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = exportFormatCSV
+	}
+	if format != exportFormatCSV && format != exportFormatNDJSON {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    CodeBadRequest,
+			Message: "invalid format: " + format,
+		})
+		return
+	}
+
+	// 响应头在写出第一条时才发送，查询出错时仍可返回JSON错误
+	var (
+		started   bool
+		count     int
+		writeLink func(link *types.GetLinkResponse) error
+		flush     func()
+	)
+	flusher, _ := w.(http.Flusher)
+	switch format {
+	case exportFormatCSV:
+		cw := csv.NewWriter(w)
+		writeLink = func(link *types.GetLinkResponse) error {
+			return cw.Write(exportRecord(link))
+		}
+		flush = cw.Flush
+	case exportFormatNDJSON:
+		enc := json.NewEncoder(w)
+		writeLink = func(link *types.GetLinkResponse) error {
+			return enc.Encode(link)
+		}
+		flush = func() {}
+	}
+
+	begin := func() error {
+		started = true
+		contentType := "text/csv; charset=utf-8"
+		if format == exportFormatNDJSON {
+			contentType = "application/x-ndjson"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": fmt.Sprintf("links-%s.%s", time.Now().Format("20060102150405"), format),
+		}))
+		if format == exportFormatCSV {
+			return writeLink(nil)
+		}
+		return nil
+	}
+
+	err = h.svc.ExportShortLinks(r.Context(), req, func(link *types.GetLinkResponse) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := writeLink(link); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeError(w, r, err)
+			return
+		}
+		// 已开始输出，只能中断连接让客户端知道导出不完整
+		panic(http.ErrAbortHandler)
+	}
+
+	// 没有数据时仍输出表头
+	if !started {
+		_ = begin()
+	}
+	flush()
+}
+
+// uploadedFile 取出上传的CSV内容
+func uploadedFile(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("file is required")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// jobID 从 /api/import/jobs/:id 和 /api/import/jobs/:id/result 中取出任务ID
+func jobID(r *http.Request) string {
+	id := strings.TrimPrefix(r.URL.Path, "/api/import/jobs/")
+	return strings.TrimSuffix(id, "/result")
+}
+
+// exportRecord 生成导出CSV中的一行，link 为空时返回表头
+func exportRecord(link *types.GetLinkResponse) []string {
+	if link == nil {
+		return exportHeader
+	}
+	record := []string{
+		link.ShortCode,
+		link.ShortURL,
+		link.OriginalURL,
+		link.Title,
+		link.Description,
+		strconv.FormatUint(link.VisitCount, 10),
+		formatTime(link.LastVisitedAt),
+		strconv.Itoa(int(link.Status)),
+		formatTime(link.StartAt),
+		formatTime(link.ExpireAt),
+		link.CreatedAt.Format(time.RFC3339),
+	}
+	utm := link.UTM
+	if utm == nil {
+		utm = &types.UTMParams{}
+	}
+	return service.EscapeCSVRecord(append(record, utm.Source, utm.Medium, utm.Campaign, utm.Term, utm.Content))
+}
+
+// formatTime 可选时间格式化为 RFC3339，为空时输出空字符串
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"shortener-service/internal/service"
+	"shortener-service/internal/types"
+)
+
+// 业务错误码
+const (
+	CodeBadRequest = 1 // 通用参数错误
+	CodeInternal   = 2 // 服务内部错误
+
+	CodeURLInvalid       = 1001 // URL格式错误
+	CodeURLTooLong       = 1002 // URL超长
+	CodeSchemeNotAllowed = 1003 // URL协议不允许
+	CodeURLBlocked       = 1004 // 目标地址命中黑名单
+
+	CodeAliasTooShort  = 1101 // 自定义短链码过短
+	CodeAliasTooLong   = 1102 // 自定义短链码过长
+	CodeAliasCharset   = 1103 // 自定义短链码含非法字符
+	CodeAliasReserved  = 1104 // 自定义短链码为保留字
+	CodeAliasProfanity = 1105 // 自定义短链码含敏感词
+
+	CodeStatusInvalid    = 1201 // 状态值错误
+	CodeCursorInvalid    = 1202 // 分页游标错误
+	CodeSortInvalid      = 1203 // 排序参数错误
+	CodeMaxVisitsInvalid = 1204 // 访问次数上限错误
+	CodeScheduleInvalid  = 1205 // 生效时间不早于过期时间
+	CodePasswordInvalid  = 1206 // 访问密码长度不符合要求
+	CodeUTMInvalid       = 1207 // UTM参数不符合要求
+
+	CodeShortCodeExists   = 1301 // 短链码已被占用
+	CodeShortCodeNotFound = 1302 // 短链码不存在
+
+	CodeBatchEmpty    = 1401 // 批量请求为空
+	CodeBatchTooLarge = 1402 // 批量请求条数超限
+	CodeBatchAborted  = 1403 // 原子批量中其他条目失败，本条未创建
+
+	CodeImportInvalid     = 1501 // 导入文件格式错误或行数超限
+	CodeImportTooLarge    = 1502 // 导入文件超过大小限制
+	CodeImportJobNotFound = 1503 // 导入任务不存在
+	CodeImportNotFinished = 1504 // 导入任务尚未完成，结果不可下载
+	CodeImportQueueFull   = 1505 // 等待处理的导入任务过多
+	CodeImportExpired     = 1506 // 导入结果已超过保留时间被删除
+
+	CodeCampaignInvalid  = 1601 // 活动名称或描述不符合要求
+	CodeCampaignNotFound = 1602 // 活动不存在
+	CodeCampaignExists   = 1603 // 活动名称已存在
+	CodeTagInvalid       = 1604 // 标签名不符合要求或标签过多
+	CodeTagNotFound      = 1605 // 标签不存在
+	CodeTagExists        = 1606 // 标签名已存在
+)
+
+// errorMapping 业务错误到HTTP状态码和错误码的映射
+type errorMapping struct {
+	err    error
+	status int
+	code   int
+}
+
+var errorMappings = []errorMapping{
+	{service.ErrURLInvalid, http.StatusBadRequest, CodeURLInvalid},
+	{service.ErrURLTooLong, http.StatusBadRequest, CodeURLTooLong},
+	{service.ErrSchemeNotAllowed, http.StatusBadRequest, CodeSchemeNotAllowed},
+	{service.ErrURLBlocked, http.StatusBadRequest, CodeURLBlocked},
+	{service.ErrAliasTooShort, http.StatusBadRequest, CodeAliasTooShort},
+	{service.ErrAliasTooLong, http.StatusBadRequest, CodeAliasTooLong},
+	{service.ErrAliasCharset, http.StatusBadRequest, CodeAliasCharset},
+	{service.ErrAliasReserved, http.StatusBadRequest, CodeAliasReserved},
+	{service.ErrAliasProfanity, http.StatusBadRequest, CodeAliasProfanity},
+	{service.ErrStatusInvalid, http.StatusBadRequest, CodeStatusInvalid},
+	{service.ErrCursorInvalid, http.StatusBadRequest, CodeCursorInvalid},
+	{service.ErrSortInvalid, http.StatusBadRequest, CodeSortInvalid},
+	{service.ErrMaxVisitsInvalid, http.StatusBadRequest, CodeMaxVisitsInvalid},
+	{service.ErrScheduleInvalid, http.StatusBadRequest, CodeScheduleInvalid},
+	{service.ErrPasswordInvalid, http.StatusBadRequest, CodePasswordInvalid},
+	{service.ErrUTMInvalid, http.StatusBadRequest, CodeUTMInvalid},
+	{service.ErrShortCodeExists, http.StatusConflict, CodeShortCodeExists},
+	{service.ErrShortCodeNotFound, http.StatusNotFound, CodeShortCodeNotFound},
+	{service.ErrBatchEmpty, http.StatusBadRequest, CodeBatchEmpty},
+	{service.ErrBatchTooLarge, http.StatusBadRequest, CodeBatchTooLarge},
+	{service.ErrBatchAborted, http.StatusFailedDependency, CodeBatchAborted},
+	{service.ErrImportInvalid, http.StatusBadRequest, CodeImportInvalid},
+	{service.ErrImportJobNotFound, http.StatusNotFound, CodeImportJobNotFound},
+	{service.ErrImportNotFinished, http.StatusConflict, CodeImportNotFinished},
+	{service.ErrImportQueueFull, http.StatusServiceUnavailable, CodeImportQueueFull},
+	{service.ErrImportExpired, http.StatusGone, CodeImportExpired},
+	{service.ErrCampaignInvalid, http.StatusBadRequest, CodeCampaignInvalid},
+	{service.ErrCampaignNotFound, http.StatusNotFound, CodeCampaignNotFound},
+	{service.ErrCampaignExists, http.StatusConflict, CodeCampaignExists},
+	{service.ErrTagInvalid, http.StatusBadRequest, CodeTagInvalid},
+	{service.ErrTagNotFound, http.StatusNotFound, CodeTagNotFound},
+	{service.ErrTagExists, http.StatusConflict, CodeTagExists},
+}
+
+// lookupError 查找业务错误对应的HTTP状态码和错误码
+func lookupError(err error) (errorMapping, bool) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m, true
+		}
+	}
+	return errorMapping{}, false
+}
+
+// internalErrorMessage 未映射错误对外展示的信息
+const internalErrorMessage = "internal error"
+
+// PublicErrorMessage 返回可以展示给调用方的错误信息
+// 未映射的数据库、Redis等内部错误只记录日志，返回通用信息
+func PublicErrorMessage(err error) string {
+	if _, ok := lookupError(err); ok {
+		return err.Error()
+	}
+	log.Printf("Internal error: %v", err)
+	return internalErrorMessage
+}
+
+// writeError 输出错误响应
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if m, ok := lookupError(err); ok {
+		httpx.WriteJsonCtx(r.Context(), w, m.status, types.CommonResponse{
+			Code:    m.code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	httpx.ErrorCtx(r.Context(), w, err)
+}
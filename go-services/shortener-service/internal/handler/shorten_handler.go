@@ -2,7 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/zeromicro/go-zero/rest/httpx"
 
@@ -41,7 +44,7 @@ func (h *ShortenHandler) CreateShortLink(w http.ResponseWriter, r *http.Request)
 
 	resp, err := h.svc.CreateShortLink(r.Context(), &req)
 	if err != nil {
-		httpx.ErrorCtx(r.Context(), w, err)
+		writeError(w, r, err)
 		return
 	}
 
@@ -67,13 +70,160 @@ func (h *ShortenHandler) GetShortLink(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.svc.GetShortLink(r.Context(), code)
 	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// UpdateShortLink 更新短链接
+func (h *ShortenHandler) UpdateShortLink(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Path[len("/api/links/"):]
+	if code == "" {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    1,
+			Message: "short_code is required",
+		})
+		return
+	}
+
+	var req types.UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		httpx.ErrorCtx(r.Context(), w, err)
 		return
 	}
 
+	resp, err := h.svc.UpdateShortLink(r.Context(), code, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// DeleteShortLink 删除短链接
+func (h *ShortenHandler) DeleteShortLink(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Path[len("/api/links/"):]
+	if code == "" {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    1,
+			Message: "short_code is required",
+		})
+		return
+	}
+
+	if err := h.svc.DeleteShortLink(r.Context(), code); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// ListShortLinks 查询短链接列表
+func (h *ShortenHandler) ListShortLinks(w http.ResponseWriter, r *http.Request) {
+	req, err := parseListRequest(r)
+	if err != nil {
+		httpx.WriteJsonCtx(r.Context(), w, http.StatusBadRequest, types.CommonResponse{
+			Code:    1,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.ListShortLinks(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
 	httpx.OkJsonCtx(r.Context(), w, types.CommonResponse{
 		Code:    0,
 		Message: "success",
 		Data:    resp,
 	})
 }
+
+// parseListRequest 从查询参数解析列表请求
+func parseListRequest(r *http.Request) (*types.ListLinksRequest, error) {
+	q := r.URL.Query()
+	req := &types.ListLinksRequest{
+		Cursor: q.Get("cursor"),
+		SortBy: q.Get("sort"),
+		Order:  q.Get("order"),
+		Query:  q.Get("q"),
+		Tags:   q["tag"],
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit: %s", v)
+		}
+		req.Limit = limit
+	}
+
+	if v := q.Get("status"); v != "" {
+		status, err := strconv.ParseInt(v, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status: %s", v)
+		}
+		st := int8(status)
+		req.Status = &st
+	}
+
+	if v := q.Get("expired"); v != "" {
+		expired, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expired: %s", v)
+		}
+		req.Expired = &expired
+	}
+
+	if v := q.Get("created_from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_from: %s", v)
+		}
+		req.CreatedFrom = &t
+	}
+
+	if v := q.Get("created_to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_to: %s", v)
+		}
+		req.CreatedTo = &t
+	}
+
+	if v := q.Get("campaign_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid campaign_id: %s", v)
+		}
+		req.CampaignID = &id
+	}
+
+	return req, nil
+}
+
+// parseTimeParam 解析时间参数，支持 RFC3339 和 2006-01-02
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
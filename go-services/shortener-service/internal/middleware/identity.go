@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// contextKey 上下文键类型
+type contextKey string
+
+const (
+	// UserIDKey 用户ID键
+	UserIDKey contextKey = "user_id"
+
+	// UserIDHeader 网关转发的可信用户ID请求头
+	UserIDHeader = "X-User-ID"
+)
+
+// IdentityMiddleware 身份中间件
+// 从网关转发的请求头中读取调用方用户ID。信任边界：
+//   - 网关对登录、注册以外的 /api 请求鉴权，删除客户端自带的 X-User-ID 后写入JWT中的用户ID，经网关的请求一定带有该请求头；
+//   - 不带 X-User-ID 的请求视为内部调用（如 redirect-service 回源），不做归属过滤，可以访问所有用户的短链接；
+//   - 本服务不校验请求来源，以上前提只靠网络隔离保证：本服务端口只能对网关和内部服务开放，不能直接暴露到公网
+type IdentityMiddleware struct{}
+
+// NewIdentityMiddleware 创建身份中间件
+func NewIdentityMiddleware() *IdentityMiddleware {
+	return &IdentityMiddleware{}
+}
+
+// Handle 处理身份解析
+func (m *IdentityMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(UserIDHeader); v != "" {
+			if userID, err := strconv.ParseUint(v, 10, 64); err == nil && userID > 0 {
+				r = r.WithContext(WithUserID(r.Context(), userID))
+			}
+		}
+		next(w, r)
+	}
+}
+
+// WithUserID 将用户ID写入上下文
+func WithUserID(ctx context.Context, userID uint64) context.Context {
+	return context.WithValue(ctx, UserIDKey, userID)
+}
+
+// GetUserID 从上下文获取用户ID，0表示内部调用（如 redirect-service 回源）
+func GetUserID(ctx context.Context) uint64 {
+	if userID, ok := ctx.Value(UserIDKey).(uint64); ok {
+		return userID
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Campaign 活动（文件夹），一个短链接最多属于一个活动
+type Campaign struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      *uint64   `gorm:"uniqueIndex:uk_campaign_owner_name" json:"user_id,omitempty"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex:uk_campaign_owner_name" json:"name"`
+	Description string    `gorm:"size:500" json:"description,omitempty"`
+	UTM         UTM       `gorm:"embedded;embeddedPrefix:utm_" json:"utm"` // UTM模板，活动下新建短链接时补全目标地址中缺少的UTM参数
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// CampaignStats 活动下短链接的汇总数据
+type CampaignStats struct {
+	CampaignID    uint64
+	LinkCount     uint64
+	VisitCount    uint64
+	LastVisitedAt *time.Time
+}
+
+// Tag 标签，与短链接多对多关联
+type Tag struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    *uint64   `gorm:"uniqueIndex:uk_tag_owner_name" json:"user_id,omitempty"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:uk_tag_owner_name" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// ShortLinkTag 短链接与标签的关联
+type ShortLinkTag struct {
+	ShortLinkID uint64 `gorm:"primaryKey"`
+	TagID       uint64 `gorm:"primaryKey;index"`
+}
+
+// TableName 指定表名
+func (ShortLinkTag) TableName() string {
+	return "short_link_tags"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IDSegment 号段分配记录
+type IDSegment struct {
+	BizTag    string    `gorm:"primaryKey;size:64" json:"biz_tag"`
+	MaxID     uint64    `gorm:"not null;default:0" json:"max_id"` // 已分配出去的最大ID
+	Step      int       `gorm:"not null" json:"step"`             // 最近一次分配的号段长度
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (IDSegment) TableName() string {
+	return "id_segments"
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// 导入任务状态
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportJob CSV批量导入任务
+type ImportJob struct {
+	ID         string     `gorm:"primaryKey;size:32" json:"id"`
+	UserID     *uint64    `gorm:"index" json:"user_id,omitempty"`
+	Status     string     `gorm:"size:16;not null" json:"status"`
+	Total      int        `json:"total"`     // 数据行数（不含表头）
+	Processed  int        `json:"processed"` // 已处理行数
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Error      string     `gorm:"size:500" json:"error,omitempty"` // 任务整体失败原因
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName 指定表名
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
+
+// Finished 任务是否已结束
+func (j *ImportJob) Finished() bool {
+	return j.Status == ImportJobCompleted || j.Status == ImportJobFailed
+}
@@ -2,21 +2,49 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// MaxShortCodeLength short_code 列长度(字符)，与 ShortLink.ShortCode 的 size 一致
+const MaxShortCodeLength = 20
+
 // ShortLink 短链接模型
 type ShortLink struct {
-	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	ShortCode   string     `gorm:"uniqueIndex;size:20;not null" json:"short_code"`
-	OriginalURL string     `gorm:"size:2048;not null" json:"original_url"`
-	UserID      *uint64    `gorm:"index" json:"user_id,omitempty"`
-	Title       string     `gorm:"size:255" json:"title,omitempty"`
-	Description string     `gorm:"size:500" json:"description,omitempty"`
-	VisitCount  uint64     `gorm:"default:0" json:"visit_count"`
-	Status      int8       `gorm:"default:1" json:"status"` // 0-禁用 1-启用
-	ExpireAt    *time.Time `json:"expire_at,omitempty"`
-	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID            uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShortCode     string         `gorm:"uniqueIndex;size:20;not null" json:"short_code"`
+	OriginalURL   string         `gorm:"size:2048;not null" json:"original_url"`
+	UserID        *uint64        `gorm:"index" json:"user_id,omitempty"`
+	CampaignID    *uint64        `gorm:"index" json:"campaign_id,omitempty"` // 所属活动（文件夹）
+	Title         string         `gorm:"size:255" json:"title,omitempty"`
+	Description   string         `gorm:"size:500" json:"description,omitempty"`
+	UTM           UTM            `gorm:"embedded;embeddedPrefix:utm_" json:"utm"` // 目标地址中的UTM参数，单独存储便于按UTM分组统计
+	VisitCount    uint64         `gorm:"default:0" json:"visit_count"`
+	LastVisitedAt *time.Time     `json:"last_visited_at,omitempty"`
+	MaxVisits     *uint64        `json:"max_visits,omitempty"`                   // 访问次数上限，达到后自动禁用，为空表示不限
+	PasswordHash  string         `gorm:"size:60" json:"password_hash,omitempty"` // 访问密码的bcrypt哈希，随缓存提供给 redirect-service 校验
+	Status        int8           `gorm:"default:1" json:"status"`                // 0-禁用 1-启用
+	StartAt       *time.Time     `json:"start_at,omitempty"`                     // 生效时间，之前不可访问，为空表示立即生效
+	ExpireAt      *time.Time     `json:"expire_at,omitempty"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // 软删除，保留墓碑行占用短链码
+
+	TagIDs []uint64 `gorm:"-" json:"-"` // 创建时待关联的标签
+}
+
+// UTM 广告追踪参数，对应URL中的 utm_source、utm_medium 等查询参数
+type UTM struct {
+	Source   string `gorm:"size:100" json:"source,omitempty"`
+	Medium   string `gorm:"size:100" json:"medium,omitempty"`
+	Campaign string `gorm:"size:100" json:"campaign,omitempty"`
+	Term     string `gorm:"size:100" json:"term,omitempty"`
+	Content  string `gorm:"size:100" json:"content,omitempty"`
+}
+
+// IsZero 是否未设置任何UTM参数
+func (u UTM) IsZero() bool {
+	return u == UTM{}
 }
 
 // TableName 指定表名
@@ -32,7 +60,20 @@ func (s *ShortLink) IsExpired() bool {
 	return time.Now().After(*s.ExpireAt)
 }
 
+// IsStarted 检查是否已到生效时间
+func (s *ShortLink) IsStarted() bool {
+	if s.StartAt == nil {
+		return true
+	}
+	return !time.Now().Before(*s.StartAt)
+}
+
+// IsExhausted 检查访问次数是否已达上限
+func (s *ShortLink) IsExhausted() bool {
+	return s.MaxVisits != nil && s.VisitCount >= *s.MaxVisits
+}
+
 // IsActive 检查是否激活
 func (s *ShortLink) IsActive() bool {
-	return s.Status == 1 && !s.IsExpired()
+	return s.Status == 1 && !s.DeletedAt.Valid && s.IsStarted() && !s.IsExpired() && !s.IsExhausted()
 }
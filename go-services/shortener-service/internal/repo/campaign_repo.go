@@ -0,0 +1,280 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"shortener-service/internal/model"
+)
+
+// CampaignRepo 活动和标签数据库操作接口
+type CampaignRepo interface {
+	CreateCampaign(ctx context.Context, campaign *model.Campaign) error
+	GetCampaign(ctx context.Context, id uint64) (*model.Campaign, error)
+	ListCampaigns(ctx context.Context, userID *uint64) ([]*model.Campaign, error)
+	UpdateCampaign(ctx context.Context, campaign *model.Campaign) error
+	DeleteCampaign(ctx context.Context, id uint64) ([]string, error)
+	GetCampaignStats(ctx context.Context, ids []uint64) (map[uint64]*model.CampaignStats, error)
+	ListCampaignCodes(ctx context.Context, ids []uint64) (map[uint64][]string, error)
+
+	CreateTag(ctx context.Context, tag *model.Tag) error
+	GetTag(ctx context.Context, id uint64) (*model.Tag, error)
+	ListTags(ctx context.Context, userID *uint64) ([]*model.Tag, error)
+	FindTagsByName(ctx context.Context, userID *uint64, names []string) ([]*model.Tag, error)
+	UpdateTag(ctx context.Context, tag *model.Tag) error
+	DeleteTag(ctx context.Context, id uint64) error
+	CountTagLinks(ctx context.Context, ids []uint64) (map[uint64]uint64, error)
+}
+
+// campaignRepo 活动和标签数据库操作实现
+type campaignRepo struct {
+	db *gorm.DB
+}
+
+// NewCampaignRepo 创建活动和标签数据库操作实例
+func NewCampaignRepo(dsn string) (CampaignRepo, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+		// 将唯一索引冲突转换为 gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	// 自动迁移表结构
+	if err := db.AutoMigrate(&model.Campaign{}, &model.Tag{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return &campaignRepo{db: db}, nil
+}
+
+// CreateCampaign 创建活动
+func (r *campaignRepo) CreateCampaign(ctx context.Context, campaign *model.Campaign) error {
+	return r.db.WithContext(ctx).Create(campaign).Error
+}
+
+// GetCampaign 根据ID查询活动
+func (r *campaignRepo) GetCampaign(ctx context.Context, id uint64) (*model.Campaign, error) {
+	var campaign model.Campaign
+	if err := r.db.WithContext(ctx).First(&campaign, id).Error; err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// ListCampaigns 查询活动列表，userID 为空表示不按用户过滤
+func (r *campaignRepo) ListCampaigns(ctx context.Context, userID *uint64) ([]*model.Campaign, error) {
+	var campaigns []*model.Campaign
+	db := r.db.WithContext(ctx)
+	if userID != nil {
+		db = db.Where("user_id = ?", *userID)
+	}
+	err := db.Order("id DESC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+// UpdateCampaign 更新活动名称、描述和UTM模板，活动已被删除时返回 gorm.ErrRecordNotFound
+func (r *campaignRepo) UpdateCampaign(ctx context.Context, campaign *model.Campaign) error {
+	// 按列更新，不能用 Save：行已被删除时 Save 会重新插入
+	result := r.db.WithContext(ctx).Model(&model.Campaign{}).
+		Where("id = ?", campaign.ID).
+		Select("name", "description", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content").
+		Updates(campaign)
+	if result.Error != nil {
+		return result.Error
+	}
+	// updated_at 每次都会变化，影响行数为0说明活动不存在
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteCampaign 删除活动，活动下的短链接移出活动，返回被移出的短链码
+func (r *campaignRepo) DeleteCampaign(ctx context.Context, id uint64) ([]string, error) {
+	var codes []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ShortLink{}).
+			Where("campaign_id = ?", id).
+			Pluck("short_code", &codes).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ShortLink{}).
+			Where("campaign_id = ?", id).
+			Update("campaign_id", nil).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.Campaign{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return codes, err
+}
+
+// GetCampaignStats 按活动汇总短链接数量和访问数据（不含已删除的短链接）
+func (r *campaignRepo) GetCampaignStats(ctx context.Context, ids []uint64) (map[uint64]*model.CampaignStats, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var rows []*model.CampaignStats
+	err := r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Select("campaign_id, COUNT(*) AS link_count, COALESCE(SUM(visit_count), 0) AS visit_count, MAX(last_visited_at) AS last_visited_at").
+		Where("campaign_id IN ?", ids).
+		Group("campaign_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uint64]*model.CampaignStats, len(rows))
+	for _, row := range rows {
+		stats[row.CampaignID] = row
+	}
+	return stats, nil
+}
+
+// ListCampaignCodes 查询每个活动下的短链码（不含已删除的短链接）
+func (r *campaignRepo) ListCampaignCodes(ctx context.Context, ids []uint64) (map[uint64][]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		CampaignID uint64
+		ShortCode  string
+	}
+	err := r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Select("campaign_id, short_code").
+		Where("campaign_id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make(map[uint64][]string)
+	for _, row := range rows {
+		codes[row.CampaignID] = append(codes[row.CampaignID], row.ShortCode)
+	}
+	return codes, nil
+}
+
+// CreateTag 创建标签
+func (r *campaignRepo) CreateTag(ctx context.Context, tag *model.Tag) error {
+	return r.db.WithContext(ctx).Create(tag).Error
+}
+
+// GetTag 根据ID查询标签
+func (r *campaignRepo) GetTag(ctx context.Context, id uint64) (*model.Tag, error) {
+	var tag model.Tag
+	if err := r.db.WithContext(ctx).First(&tag, id).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ListTags 查询标签列表，userID 为空表示不按用户过滤
+func (r *campaignRepo) ListTags(ctx context.Context, userID *uint64) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	db := r.db.WithContext(ctx)
+	if userID != nil {
+		db = db.Where("user_id = ?", *userID)
+	}
+	err := db.Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// FindTagsByName 按名称查询创建者的标签
+func (r *campaignRepo) FindTagsByName(ctx context.Context, userID *uint64, names []string) ([]*model.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var tags []*model.Tag
+	db := r.db.WithContext(ctx).Where("name IN ?", names)
+	if userID != nil {
+		db = db.Where("user_id = ?", *userID)
+	} else {
+		db = db.Where("user_id IS NULL")
+	}
+	err := db.Find(&tags).Error
+	return tags, err
+}
+
+// UpdateTag 重命名标签，标签已被删除时返回 gorm.ErrRecordNotFound
+func (r *campaignRepo) UpdateTag(ctx context.Context, tag *model.Tag) error {
+	result := r.db.WithContext(ctx).Model(&model.Tag{}).
+		Where("id = ?", tag.ID).
+		Update("name", tag.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// 名称未变化时影响行数也为0，需要确认标签是否还存在
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.Tag{}).Where("id = ?", tag.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteTag 删除标签及其与短链接的关联
+func (r *campaignRepo) DeleteTag(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&model.ShortLinkTag{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// CountTagLinks 统计每个标签关联的短链接数量（不含已删除的短链接）
+func (r *campaignRepo) CountTagLinks(ctx context.Context, ids []uint64) (map[uint64]uint64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		TagID     uint64
+		LinkCount uint64
+	}
+	err := r.db.WithContext(ctx).Model(&model.ShortLinkTag{}).
+		Select("short_link_tags.tag_id, COUNT(*) AS link_count").
+		Joins("JOIN short_links ON short_links.id = short_link_tags.short_link_id AND short_links.deleted_at IS NULL").
+		Where("short_link_tags.tag_id IN ?", ids).
+		Group("short_link_tags.tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint64]uint64, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.LinkCount
+	}
+	return counts, nil
+}
@@ -3,6 +3,8 @@ package repo
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -14,11 +16,64 @@ import (
 // ShortLinkRepo 短链接数据库操作接口
 type ShortLinkRepo interface {
 	Create(ctx context.Context, link *model.ShortLink) error
+	CreateBatch(ctx context.Context, links []*model.ShortLink) error
+	Transaction(ctx context.Context, fn func(txRepo ShortLinkRepo) error) error
 	GetByShortCode(ctx context.Context, code string) (*model.ShortLink, error)
-	GetByOriginalURL(ctx context.Context, url string) (*model.ShortLink, error)
-	Update(ctx context.Context, link *model.ShortLink) error
-	IncrementVisitCount(ctx context.Context, code string) error
-	List(ctx context.Context, offset, limit int) ([]*model.ShortLink, int64, error)
+	GetByOwnerAndURL(ctx context.Context, userID *uint64, url string) (*model.ShortLink, error)
+	Update(ctx context.Context, link *model.ShortLink, columns []string) error
+	Delete(ctx context.Context, code string) error
+	AddVisitCounts(ctx context.Context, visits map[string]VisitDelta) error
+	GetVisitStats(ctx context.Context, code string) (*model.ShortLink, error)
+	List(ctx context.Context, opts *ListOptions) ([]*model.ShortLink, error)
+	ListActiveAfter(ctx context.Context, afterID uint64, limit int) ([]*model.ShortLink, error)
+	ListCodesAfter(ctx context.Context, afterID uint64, limit int) ([]*model.ShortLink, error)
+	DisableByCodes(ctx context.Context, codes []string) error
+	DisableExhausted(ctx context.Context, code string, used uint64) (bool, error)
+	SetTags(ctx context.Context, linkID uint64, tagIDs []uint64) error
+	GetTagNames(ctx context.Context, linkIDs []uint64) (map[uint64][]string, error)
+}
+
+// VisitDelta 尚未写入数据库的访问增量
+type VisitDelta struct {
+	Count         uint64
+	LastVisitedAt time.Time // 零值表示未知
+}
+
+// Merge 合并另一份增量
+func (d *VisitDelta) Merge(other VisitDelta) {
+	d.Count += other.Count
+	if other.LastVisitedAt.After(d.LastVisitedAt) {
+		d.LastVisitedAt = other.LastVisitedAt
+	}
+}
+
+// 列表排序字段
+const (
+	SortByCreatedAt  = "created_at"
+	SortByVisitCount = "visit_count"
+)
+
+// ListOptions 列表查询条件
+type ListOptions struct {
+	UserID      *uint64 // 为空表示不按用户过滤
+	Status      *int8
+	Expired     *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Keyword     string // 在原始URL、标题、描述中模糊搜索
+	CampaignID  *uint64
+	Tags        []string // 同时带有全部标签
+	SortBy      string   // created_at | visit_count
+	Asc         bool
+	Cursor      *ListCursor // 为空表示第一页
+	Limit       int
+}
+
+// ListCursor 游标，记录上一页最后一行的排序值和ID
+type ListCursor struct {
+	CreatedAt  time.Time `json:"c,omitempty"`
+	VisitCount uint64    `json:"v,omitempty"`
+	ID         uint64    `json:"i"`
 }
 
 // shortLinkRepo 短链接数据库操作实现
@@ -30,22 +85,49 @@ type shortLinkRepo struct {
 func NewShortLinkRepo(dsn string) (ShortLinkRepo, error) {
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// 将唯一索引冲突转换为 gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
 	// 自动迁移表结构
-	if err := db.AutoMigrate(&model.ShortLink{}); err != nil {
+	if err := db.AutoMigrate(&model.ShortLink{}, &model.ShortLinkTag{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return &shortLinkRepo{db: db}, nil
 }
 
-// Create 创建短链接
+// Create 创建短链接，同一事务中写入标签关联
 func (r *shortLinkRepo) Create(ctx context.Context, link *model.ShortLink) error {
-	return r.db.WithContext(ctx).Create(link).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(link).Error; err != nil {
+			return err
+		}
+		return createTagRows(tx, []*model.ShortLink{link})
+	})
+}
+
+// CreateBatch 用一条多行INSERT语句批量创建短链接，任一行冲突时整条语句都不生效
+func (r *shortLinkRepo) CreateBatch(ctx context.Context, links []*model.ShortLink) error {
+	if len(links) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&links).Error; err != nil {
+			return err
+		}
+		return createTagRows(tx, links)
+	})
+}
+
+// Transaction 在事务中执行，fn 返回错误时回滚
+func (r *shortLinkRepo) Transaction(ctx context.Context, fn func(txRepo ShortLinkRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&shortLinkRepo{db: tx})
+	})
 }
 
 // GetByShortCode 根据短链码查询
@@ -58,42 +140,270 @@ func (r *shortLinkRepo) GetByShortCode(ctx context.Context, code string) (*model
 	return &link, nil
 }
 
-// GetByOriginalURL 根据原始URL查询
-func (r *shortLinkRepo) GetByOriginalURL(ctx context.Context, url string) (*model.ShortLink, error) {
+// GetByOwnerAndURL 根据创建者和原始URL查询最近创建的短链接
+func (r *shortLinkRepo) GetByOwnerAndURL(ctx context.Context, userID *uint64, url string) (*model.ShortLink, error) {
 	var link model.ShortLink
-	err := r.db.WithContext(ctx).Where("original_url = ?", url).First(&link).Error
+	db := r.db.WithContext(ctx).Where("original_url = ?", url)
+	if userID != nil {
+		db = db.Where("user_id = ?", *userID)
+	} else {
+		db = db.Where("user_id IS NULL")
+	}
+	err := db.Order("id DESC").First(&link).Error
 	if err != nil {
 		return nil, err
 	}
 	return &link, nil
 }
 
-// Update 更新短链接
-func (r *shortLinkRepo) Update(ctx context.Context, link *model.ShortLink) error {
-	return r.db.WithContext(ctx).Save(link).Error
+// Update 只更新指定的列，不覆盖读取之后被其他流程修改的访问次数、状态等字段；
+// 短链接已被删除时返回 gorm.ErrRecordNotFound
+func (r *shortLinkRepo) Update(ctx context.Context, link *model.ShortLink, columns []string) error {
+	// 总是写入 updated_at，未修改其他列时也能通过影响行数判断短链接是否还存在
+	selected := append([]string{"updated_at"}, columns...)
+	result := r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Where("id = ? AND deleted_at IS NULL", link.ID).
+		Select(selected).
+		Updates(link)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
-// IncrementVisitCount 增加访问次数
-func (r *shortLinkRepo) IncrementVisitCount(ctx context.Context, code string) error {
+// Delete 软删除短链接（禁用并写入deleted_at，保留墓碑行）
+func (r *shortLinkRepo) Delete(ctx context.Context, code string) error {
+	result := r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Where("short_code = ?", code).
+		UpdateColumns(map[string]interface{}{
+			"status":     0,
+			"deleted_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AddVisitCounts 用一条UPDATE语句批量增加多个短链接的访问次数，并将最后访问时间推进到较新的值
+func (r *shortLinkRepo) AddVisitCounts(ctx context.Context, visits map[string]VisitDelta) error {
+	if len(visits) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(visits))
+	countArgs := make([]interface{}, 0, len(visits)*2)
+	lastArgs := make([]interface{}, 0, len(visits)*3)
+	var countExpr, lastExpr strings.Builder
+	countExpr.WriteString("visit_count + CASE short_code")
+	lastExpr.WriteString("CASE short_code")
+	for code, delta := range visits {
+		codes = append(codes, code)
+		countArgs = append(countArgs, code, delta.Count)
+		countExpr.WriteString(" WHEN ? THEN ?")
+		if !delta.LastVisitedAt.IsZero() {
+			lastArgs = append(lastArgs, code, delta.LastVisitedAt, delta.LastVisitedAt)
+			lastExpr.WriteString(" WHEN ? THEN GREATEST(COALESCE(last_visited_at, ?), ?)")
+		}
+	}
+	countExpr.WriteString(" ELSE 0 END")
+	lastExpr.WriteString(" ELSE last_visited_at END")
+
+	columns := map[string]interface{}{
+		"visit_count": gorm.Expr(countExpr.String(), countArgs...),
+	}
+	if len(lastArgs) > 0 {
+		columns["last_visited_at"] = gorm.Expr(lastExpr.String(), lastArgs...)
+	}
+
 	return r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Where("short_code IN ?", codes).
+		UpdateColumns(columns).Error
+}
+
+// GetVisitStats 查询短链接当前的访问次数和最后访问时间
+func (r *shortLinkRepo) GetVisitStats(ctx context.Context, code string) (*model.ShortLink, error) {
+	var link model.ShortLink
+	err := r.db.WithContext(ctx).
+		Select("id", "short_code", "visit_count", "last_visited_at").
 		Where("short_code = ?", code).
-		UpdateColumn("visit_count", gorm.Expr("visit_count + ?", 1)).Error
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
 }
 
-// List 分页查询短链接列表
-func (r *shortLinkRepo) List(ctx context.Context, offset, limit int) ([]*model.ShortLink, int64, error) {
+// List 游标分页查询短链接列表
+func (r *shortLinkRepo) List(ctx context.Context, opts *ListOptions) ([]*model.ShortLink, error) {
 	var links []*model.ShortLink
-	var total int64
 
-	if err := r.db.WithContext(ctx).Model(&model.ShortLink{}).Count(&total).Error; err != nil {
-		return nil, 0, err
+	db := r.db.WithContext(ctx).Model(&model.ShortLink{})
+
+	// 过滤条件
+	if opts.UserID != nil {
+		db = db.Where("user_id = ?", *opts.UserID)
+	}
+	if opts.Status != nil {
+		db = db.Where("status = ?", *opts.Status)
+	}
+	if opts.Expired != nil {
+		now := time.Now()
+		if *opts.Expired {
+			db = db.Where("expire_at IS NOT NULL AND expire_at <= ?", now)
+		} else {
+			db = db.Where("expire_at IS NULL OR expire_at > ?", now)
+		}
+	}
+	if opts.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *opts.CreatedFrom)
+	}
+	if opts.CreatedTo != nil {
+		db = db.Where("created_at < ?", *opts.CreatedTo)
+	}
+	if opts.Keyword != "" {
+		pattern := "%" + escapeLike(opts.Keyword) + "%"
+		db = db.Where("original_url LIKE ? OR title LIKE ? OR description LIKE ?", pattern, pattern, pattern)
+	}
+	if opts.CampaignID != nil {
+		db = db.Where("campaign_id = ?", *opts.CampaignID)
+	}
+	for _, tag := range opts.Tags {
+		db = db.Where("id IN (?)", r.db.Model(&model.ShortLinkTag{}).
+			Select("short_link_tags.short_link_id").
+			Joins("JOIN tags ON tags.id = short_link_tags.tag_id").
+			Where("tags.name = ?", tag))
+	}
+
+	// 排序字段，ID作为第二排序键保证游标稳定
+	column := SortByCreatedAt
+	if opts.SortBy == SortByVisitCount {
+		column = SortByVisitCount
+	}
+	cmp, dir := "<", "DESC"
+	if opts.Asc {
+		cmp, dir = ">", "ASC"
+	}
+
+	if opts.Cursor != nil {
+		var value interface{} = opts.Cursor.CreatedAt
+		if column == SortByVisitCount {
+			value = opts.Cursor.VisitCount
+		}
+		db = db.Where(
+			fmt.Sprintf("(%s %s ?) OR (%s = ? AND id %s ?)", column, cmp, column, cmp),
+			value, value, opts.Cursor.ID,
+		)
 	}
 
+	err := db.
+		Order(fmt.Sprintf("%s %s, id %s", column, dir, dir)).
+		Limit(opts.Limit).
+		Find(&links).Error
+
+	return links, err
+}
+
+// ListActiveAfter 按ID顺序查询启用状态的短链接，用于全表扫描
+func (r *shortLinkRepo) ListActiveAfter(ctx context.Context, afterID uint64, limit int) ([]*model.ShortLink, error) {
+	var links []*model.ShortLink
 	err := r.db.WithContext(ctx).
-		Offset(offset).
+		Where("id > ? AND status = ?", afterID, 1).
+		Order("id ASC").
 		Limit(limit).
-		Order("created_at DESC").
 		Find(&links).Error
+	return links, err
+}
+
+// ListCodesAfter 按ID顺序查询未删除短链接的ID和短链码，用于全表扫描
+func (r *shortLinkRepo) ListCodesAfter(ctx context.Context, afterID uint64, limit int) ([]*model.ShortLink, error) {
+	var links []*model.ShortLink
+	err := r.db.WithContext(ctx).
+		Select("id", "short_code").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&links).Error
+	return links, err
+}
+
+// DisableByCodes 批量禁用短链接
+func (r *shortLinkRepo) DisableByCodes(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Where("short_code IN ?", codes).
+		Update("status", 0).Error
+}
+
+// DisableExhausted 访问额度用尽时禁用短链接
+// 仅在上限不超过已消耗的额度时生效，期间上限被调高或取消的短链接不受影响
+func (r *shortLinkRepo) DisableExhausted(ctx context.Context, code string, used uint64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.ShortLink{}).
+		Where("short_code = ? AND status = ? AND max_visits IS NOT NULL AND max_visits <= ?", code, 1, used).
+		Update("status", 0)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetTags 替换短链接关联的标签
+func (r *shortLinkRepo) SetTags(ctx context.Context, linkID uint64, tagIDs []uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("short_link_id = ?", linkID).Delete(&model.ShortLinkTag{}).Error; err != nil {
+			return err
+		}
+		return createTagRows(tx, []*model.ShortLink{{ID: linkID, TagIDs: tagIDs}})
+	})
+}
+
+// GetTagNames 批量查询短链接的标签名
+func (r *shortLinkRepo) GetTagNames(ctx context.Context, linkIDs []uint64) (map[uint64][]string, error) {
+	if len(linkIDs) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ShortLinkID uint64
+		Name        string
+	}
+	err := r.db.WithContext(ctx).Model(&model.ShortLinkTag{}).
+		Select("short_link_tags.short_link_id, tags.name").
+		Joins("JOIN tags ON tags.id = short_link_tags.tag_id").
+		Where("short_link_tags.short_link_id IN ?", linkIDs).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint64][]string, len(linkIDs))
+	for _, row := range rows {
+		names[row.ShortLinkID] = append(names[row.ShortLinkID], row.Name)
+	}
+	return names, nil
+}
+
+// createTagRows 写入短链接的 TagIDs 关联，需在创建短链接后调用
+func createTagRows(tx *gorm.DB, links []*model.ShortLink) error {
+	var rows []model.ShortLinkTag
+	for _, link := range links {
+		for _, tagID := range link.TagIDs {
+			rows = append(rows, model.ShortLinkTag{ShortLinkID: link.ID, TagID: tagID})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
 
-	return links, total, err
+// escapeLike 转义LIKE通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"shortener-service/internal/model"
+)
+
+// ImportJobRepo 导入任务数据库操作接口
+type ImportJobRepo interface {
+	Create(ctx context.Context, job *model.ImportJob) error
+	GetByID(ctx context.Context, id string) (*model.ImportJob, error)
+	UpdateIfStatus(ctx context.Context, job *model.ImportJob, status string, columns ...string) error
+	FailStale(ctx context.Context, before time.Time, reason string) (int64, error)
+}
+
+// ErrImportJobStatusChanged 任务状态已被其他流程修改（如其他实例启动时标记为失败）
+var ErrImportJobStatusChanged = errors.New("import job status changed")
+
+// importJobRepo 导入任务数据库操作实现
+type importJobRepo struct {
+	db *gorm.DB
+}
+
+// NewImportJobRepo 创建导入任务数据库操作实例
+func NewImportJobRepo(dsn string) (ImportJobRepo, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	// 自动迁移表结构
+	if err := db.AutoMigrate(&model.ImportJob{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return &importJobRepo{db: db}, nil
+}
+
+// Create 创建导入任务
+func (r *importJobRepo) Create(ctx context.Context, job *model.ImportJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// GetByID 根据ID查询导入任务
+func (r *importJobRepo) GetByID(ctx context.Context, id string) (*model.ImportJob, error) {
+	var job model.ImportJob
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateIfStatus 任务仍处于 status 状态时更新指定的列，否则返回 ErrImportJobStatusChanged
+func (r *importJobRepo) UpdateIfStatus(ctx context.Context, job *model.ImportJob, status string, columns ...string) error {
+	// updated_at 每次都会写入，影响行数为0说明状态已变化
+	result := r.db.WithContext(ctx).Model(&model.ImportJob{}).
+		Where("id = ? AND status = ?", job.ID, status).
+		Select(columns).
+		Updates(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrImportJobStatusChanged
+	}
+	return nil
+}
+
+// FailStale 把 before 之后没有更新过的未结束任务标记为失败，返回标记的任务数
+func (r *importJobRepo) FailStale(ctx context.Context, before time.Time, reason string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&model.ImportJob{}).
+		Where("status IN ? AND updated_at < ?", []string{model.ImportJobPending, model.ImportJobRunning}, before).
+		Updates(map[string]interface{}{
+			"status":      model.ImportJobFailed,
+			"error":       reason,
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
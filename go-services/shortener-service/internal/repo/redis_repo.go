@@ -3,7 +3,10 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,19 +17,134 @@ import (
 const (
 	// 短链码 -> 原始URL映射的key前缀
 	shortCodePrefix = "short:code:"
-	// 原始URL -> 短链码映射的key前缀
+	// 原始URL -> 短链码映射的key前缀，按创建者隔离: short:url:<user_id>:<url>
 	originalURLPrefix = "short:url:"
+	// 计数器策略的自增ID key
+	idCounterKey = "short:id:counter"
+	// 雪花算法机器ID租约key前缀: snowflake:lease:<machine_id>
+	machineLeasePrefix = "snowflake:lease:"
+	// 雪花算法机器ID最后使用时间key前缀，不过期，用于检测时钟回拨
+	machineLastTSPrefix = "snowflake:last_ts:"
+	// 短链码布隆过滤器位图key
+	codeBloomKey = "short:bloom"
+	// 重建中的布隆过滤器位图key，重建完成后替换 codeBloomKey
+	codeBloomBuildingKey = "short:bloom:building"
+	// 布隆过滤器重建锁，避免多个实例同时重建
+	codeBloomLockKey = "short:bloom:lock"
+	// 短链变更通知频道，redirect-service 据此失效进程内缓存，消息内容为短链码
+	linkChangedChannel = "short:link:changed"
+	// redirect-service 写入的访问计数key前缀，定期合并到数据库
+	visitCountPrefix = "visit:count:"
+	// redirect-service 写入的最后访问时间(毫秒)key前缀
+	visitLastPrefix = "visit:last:"
+	// 已消耗的访问额度，redirect-service 在重定向前原子占用，与 visit:count: 不同，不会被合并任务取走
+	visitQuotaPrefix = "visit:quota:"
+	// 访问额度已用尽、等待在数据库中禁用的短链码集合
+	visitExhaustedKey = "visit:exhausted"
+	// 负缓存占位值，表示短链码不存在或已删除
+	notFoundPlaceholder = "-"
 )
 
+// ErrNotFoundCached 负缓存命中
+var ErrNotFoundCached = errors.New("short code cached as not found")
+
 // RedisRepo Redis缓存操作接口
 type RedisRepo interface {
 	SetShortLink(ctx context.Context, link *model.ShortLink, ttl time.Duration) error
-	GetShortLink(ctx context.Context, code string) (*model.ShortLink, error)
-	GetShortCodeByURL(ctx context.Context, url string) (string, error)
-	DeleteShortLink(ctx context.Context, code string) error
+	GetShortLink(ctx context.Context, code string) (*model.ShortLink, time.Duration, error)
+	GetShortCodeByURL(ctx context.Context, userID *uint64, url string) (string, error)
+	DeleteShortLink(ctx context.Context, link *model.ShortLink) error
+	SetNotFound(ctx context.Context, code string, ttl time.Duration) error
 	Exists(ctx context.Context, code string) (bool, error)
+	NextID(ctx context.Context) (int64, error)
+	AcquireMachineLease(ctx context.Context, machineID int64, token string, ttl time.Duration) (bool, error)
+	RenewMachineLease(ctx context.Context, machineID int64, token string, ttl time.Duration, lastMillis int64) (bool, error)
+	ReleaseMachineLease(ctx context.Context, machineID int64, token string, lastMillis int64) error
+	GetMachineLastTimestamp(ctx context.Context, machineID int64) (int64, error)
+	BloomAdd(ctx context.Context, offsets []uint64) error
+	BloomTest(ctx context.Context, offsets []uint64) (bool, error)
+	BloomBeginRebuild(ctx context.Context, bits uint64, lockTTL time.Duration) (bool, error)
+	BloomAddRebuild(ctx context.Context, offsets []uint64) error
+	BloomCommitRebuild(ctx context.Context) error
+	BloomAbortRebuild(ctx context.Context) error
+	ScanVisitCodes(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error)
+	TakeVisitCounts(ctx context.Context, codes []string) (map[string]VisitDelta, error)
+	RestoreVisitCounts(ctx context.Context, visits map[string]VisitDelta) error
+	GetPendingVisits(ctx context.Context, codes []string) (map[string]VisitDelta, error)
+	SetVisitQuota(ctx context.Context, code string, used uint64, expireAt *time.Time) error
+	ExpireVisitQuota(ctx context.Context, code string, expireAt *time.Time) error
+	ClearVisitQuota(ctx context.Context, code string) error
+	TakeExhaustedCodes(ctx context.Context, count int64) (map[string]uint64, error)
+	RestoreExhaustedCodes(ctx context.Context, codes []string) error
 }
 
+// takeVisitCountsScript 原子地取出并清除一批访问计数，取出后新的访问从0重新累加
+var takeVisitCountsScript = redis.NewScript(`
+	local counts = {}
+	for i, key in ipairs(KEYS) do
+		local v = redis.call('GET', key)
+		if v then
+			redis.call('DEL', key)
+		end
+		counts[i] = v or '0'
+	end
+	return counts
+`)
+
+// bloomAddScript 置位布隆过滤器，重建进行中时同时写入重建中的位图，避免重建期间新增的短链码丢失
+// 位图尚未构建时不创建，否则只含新增短链码的位图会把其他已存在的短链码判定为不存在；重建完成后由 RENAME 补齐
+var bloomAddScript = redis.NewScript(`
+	local built = redis.call('EXISTS', KEYS[1]) == 1
+	local building = redis.call('EXISTS', KEYS[2]) == 1
+	for i = 1, #ARGV do
+		if built then
+			redis.call('SETBIT', KEYS[1], ARGV[i], 1)
+		end
+		if building then
+			redis.call('SETBIT', KEYS[2], ARGV[i], 1)
+		end
+	end
+	return 1
+`)
+
+// bloomTestScript 检查布隆过滤器，位图不存在（尚未构建）时视为可能存在
+var bloomTestScript = redis.NewScript(`
+	if redis.call('EXISTS', KEYS[1]) == 0 then
+		return 1
+	end
+	for i = 1, #ARGV do
+		if redis.call('GETBIT', KEYS[1], ARGV[i]) == 0 then
+			return 0
+		end
+	end
+	return 1
+`)
+
+// renewLeaseScript 续约租约：持有者匹配时延长过期时间，并单调更新最后使用时间
+var renewLeaseScript = redis.NewScript(`
+	if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+		return 0
+	end
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	local last = tonumber(redis.call('GET', KEYS[2]) or '0')
+	if tonumber(ARGV[3]) > last then
+		redis.call('SET', KEYS[2], ARGV[3])
+	end
+	return 1
+`)
+
+// releaseLeaseScript 释放租约：持有者匹配时删除，并单调更新最后使用时间
+var releaseLeaseScript = redis.NewScript(`
+	local last = tonumber(redis.call('GET', KEYS[2]) or '0')
+	if tonumber(ARGV[2]) > last then
+		redis.call('SET', KEYS[2], ARGV[2])
+	end
+	if redis.call('GET', KEYS[1]) == ARGV[1] then
+		return redis.call('DEL', KEYS[1])
+	end
+	return 0
+`)
+
 // redisRepo Redis缓存操作实现
 type redisRepo struct {
 	client *redis.Client
@@ -52,12 +170,17 @@ func NewRedisRepo(host, password string, db int) (RedisRepo, error) {
 }
 
 // SetShortLink 缓存短链接信息
+// 过期时间增加 [0, ttl/10) 的随机抖动，避免同时写入的key同时过期
 func (r *redisRepo) SetShortLink(ctx context.Context, link *model.ShortLink, ttl time.Duration) error {
 	data, err := json.Marshal(link)
 	if err != nil {
 		return err
 	}
 
+	if jitter := int64(ttl / 10); jitter > 0 {
+		ttl += time.Duration(rand.Int63n(jitter))
+	}
+
 	// 缓存短链码 -> 完整信息
 	codeKey := shortCodePrefix + link.ShortCode
 	if err := r.client.Set(ctx, codeKey, data, ttl).Err(); err != nil {
@@ -65,32 +188,43 @@ func (r *redisRepo) SetShortLink(ctx context.Context, link *model.ShortLink, ttl
 	}
 
 	// 缓存原始URL -> 短链码
-	urlKey := originalURLPrefix + link.OriginalURL
+	urlKey := originalURLKey(link.UserID, link.OriginalURL)
 	return r.client.Set(ctx, urlKey, link.ShortCode, ttl).Err()
 }
 
-// GetShortLink 从缓存获取短链接信息
-func (r *redisRepo) GetShortLink(ctx context.Context, code string) (*model.ShortLink, error) {
+// GetShortLink 从缓存获取短链接信息及剩余过期时间
+func (r *redisRepo) GetShortLink(ctx context.Context, code string) (*model.ShortLink, time.Duration, error) {
 	key := shortCodePrefix + code
-	data, err := r.client.Get(ctx, key).Bytes()
+
+	pipe := r.client.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	_, _ = pipe.Exec(ctx)
+
+	data, err := getCmd.Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, nil // 缓存未命中
+			return nil, 0, nil // 缓存未命中
 		}
-		return nil, err
+		return nil, 0, err
+	}
+
+	if string(data) == notFoundPlaceholder {
+		return nil, 0, ErrNotFoundCached
 	}
 
 	var link model.ShortLink
 	if err := json.Unmarshal(data, &link); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return &link, nil
+	// 未设置过期时间时PTTL为负数
+	return &link, ttlCmd.Val(), nil
 }
 
-// GetShortCodeByURL 根据原始URL获取短链码
-func (r *redisRepo) GetShortCodeByURL(ctx context.Context, url string) (string, error) {
-	key := originalURLPrefix + url
+// GetShortCodeByURL 根据创建者和原始URL获取短链码
+func (r *redisRepo) GetShortCodeByURL(ctx context.Context, userID *uint64, url string) (string, error) {
+	key := originalURLKey(userID, url)
 	code, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
@@ -101,18 +235,336 @@ func (r *redisRepo) GetShortCodeByURL(ctx context.Context, url string) (string,
 	return code, nil
 }
 
-// DeleteShortLink 删除短链接缓存
-func (r *redisRepo) DeleteShortLink(ctx context.Context, code string) error {
+// DeleteShortLink 删除短链接缓存（短链码和原始URL两类key），并发布变更通知
+func (r *redisRepo) DeleteShortLink(ctx context.Context, link *model.ShortLink) error {
+	keys := []string{shortCodePrefix + link.ShortCode}
+	if link.OriginalURL != "" {
+		keys = append(keys, originalURLKey(link.UserID, link.OriginalURL))
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.Del(ctx, keys...)
+	pipe.Publish(ctx, linkChangedChannel, link.ShortCode)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetNotFound 写入负缓存，重定向服务读到占位值后直接返回404
+func (r *redisRepo) SetNotFound(ctx context.Context, code string, ttl time.Duration) error {
 	key := shortCodePrefix + code
-	return r.client.Del(ctx, key).Err()
+	return r.client.Set(ctx, key, notFoundPlaceholder, ttl).Err()
 }
 
-// Exists 检查短链码是否存在
+// Exists 检查短链码是否存在（负缓存不算存在）
 func (r *redisRepo) Exists(ctx context.Context, code string) (bool, error) {
 	key := shortCodePrefix + code
-	count, err := r.client.Exists(ctx, key).Result()
+	val, err := r.client.Get(ctx, key).Result()
 	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
 		return false, err
 	}
-	return count > 0, nil
+	return val != notFoundPlaceholder, nil
+}
+
+// NextID 自增并返回全局ID计数器
+func (r *redisRepo) NextID(ctx context.Context) (int64, error) {
+	return r.client.Incr(ctx, idCounterKey).Result()
+}
+
+// AcquireMachineLease 尝试获取机器ID租约
+func (r *redisRepo) AcquireMachineLease(ctx context.Context, machineID int64, token string, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf("%s%d", machineLeasePrefix, machineID)
+	return r.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+// RenewMachineLease 续约机器ID租约，租约已不属于自己时返回false
+func (r *redisRepo) RenewMachineLease(ctx context.Context, machineID int64, token string, ttl time.Duration, lastMillis int64) (bool, error) {
+	keys := []string{
+		fmt.Sprintf("%s%d", machineLeasePrefix, machineID),
+		fmt.Sprintf("%s%d", machineLastTSPrefix, machineID),
+	}
+	n, err := renewLeaseScript.Run(ctx, r.client, keys, token, ttl.Milliseconds(), lastMillis).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ReleaseMachineLease 释放机器ID租约
+func (r *redisRepo) ReleaseMachineLease(ctx context.Context, machineID int64, token string, lastMillis int64) error {
+	keys := []string{
+		fmt.Sprintf("%s%d", machineLeasePrefix, machineID),
+		fmt.Sprintf("%s%d", machineLastTSPrefix, machineID),
+	}
+	return releaseLeaseScript.Run(ctx, r.client, keys, token, lastMillis).Err()
+}
+
+// GetMachineLastTimestamp 获取机器ID最后一次生成ID的时间(毫秒)，从未使用过时返回0
+func (r *redisRepo) GetMachineLastTimestamp(ctx context.Context, machineID int64) (int64, error) {
+	key := fmt.Sprintf("%s%d", machineLastTSPrefix, machineID)
+	ts, err := r.client.Get(ctx, key).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return ts, nil
+}
+
+// BloomAdd 将短链码的哈希位写入布隆过滤器
+func (r *redisRepo) BloomAdd(ctx context.Context, offsets []uint64) error {
+	return bloomAddScript.Run(ctx, r.client, []string{codeBloomKey, codeBloomBuildingKey}, uint64Args(offsets)...).Err()
+}
+
+// BloomTest 检查哈希位是否全部置位，false 表示一定不存在
+func (r *redisRepo) BloomTest(ctx context.Context, offsets []uint64) (bool, error) {
+	n, err := bloomTestScript.Run(ctx, r.client, []string{codeBloomKey}, uint64Args(offsets)...).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// BloomBeginRebuild 获取重建锁并创建全零的重建位图，锁被其他实例持有时返回false
+func (r *redisRepo) BloomBeginRebuild(ctx context.Context, bits uint64, lockTTL time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(ctx, codeBloomLockKey, 1, lockTTL).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, codeBloomBuildingKey)
+	// 置零最后一位，一次性分配整个位图
+	pipe.SetBit(ctx, codeBloomBuildingKey, int64(bits-1), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.client.Del(ctx, codeBloomLockKey)
+		return false, err
+	}
+	return true, nil
+}
+
+// BloomAddRebuild 向重建中的位图批量置位
+func (r *redisRepo) BloomAddRebuild(ctx context.Context, offsets []uint64) error {
+	pipe := r.client.Pipeline()
+	for _, offset := range offsets {
+		pipe.SetBit(ctx, codeBloomBuildingKey, int64(offset), 1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// BloomCommitRebuild 用重建完成的位图替换当前位图并释放重建锁
+func (r *redisRepo) BloomCommitRebuild(ctx context.Context) error {
+	pipe := r.client.TxPipeline()
+	pipe.Rename(ctx, codeBloomBuildingKey, codeBloomKey)
+	pipe.Del(ctx, codeBloomLockKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// BloomAbortRebuild 放弃重建
+func (r *redisRepo) BloomAbortRebuild(ctx context.Context) error {
+	return r.client.Del(ctx, codeBloomBuildingKey, codeBloomLockKey).Err()
+}
+
+// ScanVisitCodes 增量扫描存在访问计数的短链码
+func (r *redisRepo) ScanVisitCodes(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	keys, next, err := r.client.Scan(ctx, cursor, visitCountPrefix+"*", count).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	codes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		codes = append(codes, key[len(visitCountPrefix):])
+	}
+	return codes, next, nil
+}
+
+// TakeVisitCounts 原子地取出一批短链码的访问计数，并附带最后访问时间
+func (r *redisRepo) TakeVisitCounts(ctx context.Context, codes []string) (map[string]VisitDelta, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = visitCountPrefix + code
+	}
+	values, err := takeVisitCountsScript.Run(ctx, r.client, keys).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	lasts, err := r.getVisitLasts(ctx, codes)
+	if err != nil {
+		// 计数已取出，不能因为时间读取失败丢弃
+		lasts = nil
+	}
+
+	visits := make(map[string]VisitDelta, len(codes))
+	for i, code := range codes {
+		count, _ := strconv.ParseUint(values[i], 10, 64)
+		if count == 0 {
+			continue
+		}
+		delta := VisitDelta{Count: count}
+		if lasts != nil {
+			delta.LastVisitedAt = lasts[i]
+		}
+		visits[code] = delta
+	}
+	return visits, nil
+}
+
+// RestoreVisitCounts 写库失败时把取出的访问计数加回
+func (r *redisRepo) RestoreVisitCounts(ctx context.Context, visits map[string]VisitDelta) error {
+	pipe := r.client.Pipeline()
+	for code, delta := range visits {
+		pipe.IncrBy(ctx, visitCountPrefix+code, int64(delta.Count))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetPendingVisits 查询尚未合并到数据库的访问计数和最后访问时间
+func (r *redisRepo) GetPendingVisits(ctx context.Context, codes []string) (map[string]VisitDelta, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = visitCountPrefix + code
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	lasts, err := r.getVisitLasts(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	visits := make(map[string]VisitDelta, len(codes))
+	for i, code := range codes {
+		var delta VisitDelta
+		if v, ok := values[i].(string); ok {
+			delta.Count, _ = strconv.ParseUint(v, 10, 64)
+		}
+		delta.LastVisitedAt = lasts[i]
+		if delta.Count > 0 || !delta.LastVisitedAt.IsZero() {
+			visits[code] = delta
+		}
+	}
+	return visits, nil
+}
+
+// SetVisitQuota 修改访问次数上限后重置已消耗的额度，计数随短链接过期一起过期
+func (r *redisRepo) SetVisitQuota(ctx context.Context, code string, used uint64, expireAt *time.Time) error {
+	key := visitQuotaPrefix + code
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, key, used, 0)
+	if expireAt != nil {
+		pipe.ExpireAt(ctx, key, *expireAt)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ExpireVisitQuota 修改过期时间后同步额度计数的过期时间，expireAt 为空表示不过期
+func (r *redisRepo) ExpireVisitQuota(ctx context.Context, code string, expireAt *time.Time) error {
+	key := visitQuotaPrefix + code
+	if expireAt == nil {
+		return r.client.Persist(ctx, key).Err()
+	}
+	return r.client.ExpireAt(ctx, key, *expireAt).Err()
+}
+
+// ClearVisitQuota 取消访问次数上限后删除额度计数
+func (r *redisRepo) ClearVisitQuota(ctx context.Context, code string) error {
+	return r.client.Del(ctx, visitQuotaPrefix+code).Err()
+}
+
+// TakeExhaustedCodes 取出一批额度已用尽的短链码及其已消耗的额度
+func (r *redisRepo) TakeExhaustedCodes(ctx context.Context, count int64) (map[string]uint64, error) {
+	codes, err := r.client.SPopN(ctx, visitExhaustedKey, count).Result()
+	if err != nil || len(codes) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = visitQuotaPrefix + code
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		// 放回集合，下次重试
+		_ = r.RestoreExhaustedCodes(ctx, codes)
+		return nil, err
+	}
+
+	used := make(map[string]uint64, len(codes))
+	for i, code := range codes {
+		if v, ok := values[i].(string); ok {
+			used[code], _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	return used, nil
+}
+
+// RestoreExhaustedCodes 把未能在数据库中禁用的短链码放回集合，下次重试
+func (r *redisRepo) RestoreExhaustedCodes(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(codes))
+	for i, code := range codes {
+		members[i] = code
+	}
+	return r.client.SAdd(ctx, visitExhaustedKey, members...).Err()
+}
+
+// getVisitLasts 批量读取最后访问时间，不存在时为零值
+func (r *redisRepo) getVisitLasts(ctx context.Context, codes []string) ([]time.Time, error) {
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = visitLastPrefix + code
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	lasts := make([]time.Time, len(codes))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if millis, err := strconv.ParseInt(s, 10, 64); err == nil {
+			lasts[i] = time.UnixMilli(millis)
+		}
+	}
+	return lasts, nil
+}
+
+// uint64Args 转换为脚本参数
+func uint64Args(values []uint64) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// originalURLKey 生成按创建者隔离的原始URL缓存key，匿名创建的短链使用0
+func originalURLKey(userID *uint64, url string) string {
+	var owner uint64
+	if userID != nil {
+		owner = *userID
+	}
+	return fmt.Sprintf("%s%d:%s", originalURLPrefix, owner, url)
 }
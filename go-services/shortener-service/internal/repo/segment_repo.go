@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"shortener-service/internal/model"
+)
+
+// SegmentRepo 号段分配数据库操作接口
+type SegmentRepo interface {
+	// NextSegment 原子地分配下一个号段，返回区间 [start, end]
+	NextSegment(ctx context.Context, bizTag string, step int) (start, end uint64, err error)
+}
+
+// segmentRepo 号段分配数据库操作实现
+type segmentRepo struct {
+	db *gorm.DB
+}
+
+// NewSegmentRepo 创建号段分配数据库操作实例
+func NewSegmentRepo(dsn string) (SegmentRepo, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Warn),
+		TranslateError: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	// 自动迁移表结构
+	if err := db.AutoMigrate(&model.IDSegment{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return &segmentRepo{db: db}, nil
+}
+
+// 首次分配时并发插入冲突的最大重试次数
+const segmentMaxAttempts = 3
+
+// NextSegment 原子地分配下一个号段
+// 在事务内先递增 max_id 再读回，行锁保证多个实例拿到的号段互不重叠；
+// 首次分配时并发插入冲突则重试，超过 segmentMaxAttempts 次后返回错误
+func (r *segmentRepo) NextSegment(ctx context.Context, bizTag string, step int) (uint64, uint64, error) {
+	if step <= 0 {
+		return 0, 0, fmt.Errorf("invalid segment step: %d", step)
+	}
+
+	var err error
+	for attempt := 0; attempt < segmentMaxAttempts; attempt++ {
+		var seg *model.IDSegment
+		seg, err = r.allocSegment(ctx, bizTag, step)
+		if err == nil {
+			return seg.MaxID - uint64(seg.Step) + 1, seg.MaxID, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return 0, 0, err
+		}
+	}
+	return 0, 0, fmt.Errorf("failed to allocate segment after %d attempts: %w", segmentMaxAttempts, err)
+}
+
+// allocSegment 在一个事务内递增号段，记录不存在时插入
+func (r *segmentRepo) allocSegment(ctx context.Context, bizTag string, step int) (*model.IDSegment, error) {
+	var seg model.IDSegment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.IDSegment{}).
+			Where("biz_tag = ?", bizTag).
+			Updates(map[string]interface{}{
+				"max_id": gorm.Expr("max_id + ?", step),
+				"step":   step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			// 首次分配，插入记录；并发插入冲突时由调用方重试
+			seg = model.IDSegment{BizTag: bizTag, MaxID: uint64(step), Step: step}
+			return tx.Create(&seg).Error
+		}
+
+		return tx.Where("biz_tag = ?", bizTag).First(&seg).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &seg, nil
+}
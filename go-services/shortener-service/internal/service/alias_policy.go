@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"shortener-service/internal/model"
+)
+
+var (
+	ErrAliasTooShort  = errors.New("custom code too short")
+	ErrAliasTooLong   = errors.New("custom code too long")
+	ErrAliasCharset   = errors.New("custom code contains invalid characters")
+	ErrAliasReserved  = errors.New("custom code is reserved")
+	ErrAliasProfanity = errors.New("custom code contains inappropriate words")
+)
+
+// 大小写处理规则
+const (
+	CaseFoldingPreserve = "preserve" // 保留原样，短链码区分大小写
+	CaseFoldingLower    = "lower"    // 统一转为小写
+)
+
+// unsafeAliasChars 无论如何配置都不允许出现在短链码中的字符
+// "/" 会破坏 /api/links/:code 的路径截取和网关的重定向路径判断，其余为URL保留字符
+const unsafeAliasChars = "/?#%&=+ \\.:@"
+
+// leetReplacer 匹配敏感词前还原常见的数字替代写法
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+	"-", "", "_", "",
+)
+
+// AliasPolicy 自定义短链码策略
+type AliasPolicy struct {
+	charset     map[rune]bool
+	minLength   int
+	maxLength   int
+	reserved    map[string]struct{}
+	profanity   []string
+	caseFolding string
+}
+
+// NewAliasPolicy 创建自定义短链码策略
+func NewAliasPolicy(
+	charset string,
+	minLength, maxLength int,
+	reserved, profanity []string,
+	caseFolding string,
+) (*AliasPolicy, error) {
+	if minLength <= 0 || maxLength < minLength {
+		return nil, fmt.Errorf("invalid alias length range [%d, %d]", minLength, maxLength)
+	}
+	if maxLength > model.MaxShortCodeLength {
+		return nil, fmt.Errorf("alias max length %d exceeds short code column length %d", maxLength, model.MaxShortCodeLength)
+	}
+
+	switch caseFolding {
+	case CaseFoldingPreserve, CaseFoldingLower:
+	default:
+		return nil, fmt.Errorf("invalid alias case folding: %s", caseFolding)
+	}
+
+	p := &AliasPolicy{
+		charset:     make(map[rune]bool, len(charset)),
+		minLength:   minLength,
+		maxLength:   maxLength,
+		reserved:    make(map[string]struct{}, len(reserved)),
+		caseFolding: caseFolding,
+	}
+
+	for _, c := range charset {
+		if strings.ContainsRune(unsafeAliasChars, c) {
+			return nil, fmt.Errorf("alias charset must not contain %q", c)
+		}
+		p.charset[c] = true
+	}
+
+	for _, word := range reserved {
+		p.reserved[strings.ToLower(word)] = struct{}{}
+	}
+
+	for _, word := range profanity {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			p.profanity = append(p.profanity, word)
+		}
+	}
+
+	return p, nil
+}
+
+// LoadProfanityFile 从文件追加敏感词，每行一个，"#" 开头为注释
+func (p *AliasPolicy) LoadProfanityFile(path string) error {
+	return readListFile(path, func(line string) error {
+		p.profanity = append(p.profanity, strings.ToLower(line))
+		return nil
+	})
+}
+
+// Normalize 校验自定义短链码并按大小写规则返回最终使用的短链码
+func (p *AliasPolicy) Normalize(alias string) (string, error) {
+	if p.caseFolding == CaseFoldingLower {
+		alias = strings.ToLower(alias)
+	}
+
+	length := len([]rune(alias))
+	if length < p.minLength {
+		return "", fmt.Errorf("%w: minimum length is %d", ErrAliasTooShort, p.minLength)
+	}
+	if length > p.maxLength {
+		return "", fmt.Errorf("%w: maximum length is %d", ErrAliasTooLong, p.maxLength)
+	}
+
+	for _, c := range alias {
+		if !p.charset[c] {
+			return "", fmt.Errorf("%w: %q", ErrAliasCharset, c)
+		}
+	}
+
+	// 保留字和敏感词始终不区分大小写
+	folded := strings.ToLower(alias)
+	if _, ok := p.reserved[folded]; ok {
+		return "", fmt.Errorf("%w: %s", ErrAliasReserved, alias)
+	}
+
+	plain := leetReplacer.Replace(folded)
+	for _, word := range p.profanity {
+		if strings.Contains(folded, word) || strings.Contains(plain, word) {
+			return "", ErrAliasProfanity
+		}
+	}
+
+	return alias, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testAliasCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
+
+func TestAliasPolicyNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		caseFolding string
+		alias       string
+		want        string
+		wantErr     error
+	}{
+		{name: "valid", caseFolding: CaseFoldingPreserve, alias: "MyLink-1", want: "MyLink-1"},
+		{name: "preserve keeps case", caseFolding: CaseFoldingPreserve, alias: "ABCD", want: "ABCD"},
+		{name: "lower folds case", caseFolding: CaseFoldingLower, alias: "MyLink", want: "mylink"},
+		{name: "min length", caseFolding: CaseFoldingPreserve, alias: "abcd", want: "abcd"},
+		{name: "max length", caseFolding: CaseFoldingPreserve, alias: "abcdefghij", want: "abcdefghij"},
+		{name: "too short", caseFolding: CaseFoldingPreserve, alias: "abc", wantErr: ErrAliasTooShort},
+		{name: "too long", caseFolding: CaseFoldingPreserve, alias: "abcdefghijk", wantErr: ErrAliasTooLong},
+		{name: "length counts runes", caseFolding: CaseFoldingPreserve, alias: "链接链接", wantErr: ErrAliasCharset},
+		{name: "char outside charset", caseFolding: CaseFoldingPreserve, alias: "ab!cd", wantErr: ErrAliasCharset},
+		{name: "slash", caseFolding: CaseFoldingPreserve, alias: "ab/cd", wantErr: ErrAliasCharset},
+		{name: "reserved", caseFolding: CaseFoldingPreserve, alias: "admin", wantErr: ErrAliasReserved},
+		{name: "reserved ignores case", caseFolding: CaseFoldingPreserve, alias: "AdMiN", wantErr: ErrAliasReserved},
+		{name: "reserved only exact", caseFolding: CaseFoldingPreserve, alias: "admins", want: "admins"},
+		{name: "profanity substring", caseFolding: CaseFoldingPreserve, alias: "xxbadwordx", wantErr: ErrAliasProfanity},
+		{name: "profanity ignores case", caseFolding: CaseFoldingPreserve, alias: "BADWORD", wantErr: ErrAliasProfanity},
+		{name: "profanity leet", caseFolding: CaseFoldingPreserve, alias: "b4dw0rd", wantErr: ErrAliasProfanity},
+		{name: "profanity separators", caseFolding: CaseFoldingPreserve, alias: "bad-word", wantErr: ErrAliasProfanity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewAliasPolicy(testAliasCharset, 4, 10, []string{"Admin", "api"}, []string{" BadWord "}, tt.caseFolding)
+			if err != nil {
+				t.Fatalf("NewAliasPolicy: %v", err)
+			}
+
+			got, err := p.Normalize(tt.alias)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Normalize(%q) error = %v, want %v", tt.alias, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.alias, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAliasPolicyInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		charset     string
+		minLength   int
+		maxLength   int
+		caseFolding string
+	}{
+		{name: "zero min length", charset: testAliasCharset, minLength: 0, maxLength: 10, caseFolding: CaseFoldingPreserve},
+		{name: "max below min", charset: testAliasCharset, minLength: 5, maxLength: 4, caseFolding: CaseFoldingPreserve},
+		{name: "max above column length", charset: testAliasCharset, minLength: 4, maxLength: 21, caseFolding: CaseFoldingPreserve},
+		{name: "unknown case folding", charset: testAliasCharset, minLength: 4, maxLength: 10, caseFolding: "upper"},
+		{name: "unsafe charset", charset: "abc/", minLength: 4, maxLength: 10, caseFolding: CaseFoldingPreserve},
+		{name: "dot in charset", charset: "abc.", minLength: 4, maxLength: 10, caseFolding: CaseFoldingPreserve},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAliasPolicy(tt.charset, tt.minLength, tt.maxLength, nil, nil, tt.caseFolding); err == nil {
+				t.Error("NewAliasPolicy succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestAliasPolicyLoadProfanityFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profanity.txt")
+	if err := os.WriteFile(path, []byte("# comment\n\nSpamWord\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewAliasPolicy(testAliasCharset, 4, 20, nil, nil, CaseFoldingPreserve)
+	if err != nil {
+		t.Fatalf("NewAliasPolicy: %v", err)
+	}
+	if err := p.LoadProfanityFile(path); err != nil {
+		t.Fatalf("LoadProfanityFile: %v", err)
+	}
+
+	if _, err := p.Normalize("mySpamWord1"); !errors.Is(err, ErrAliasProfanity) {
+		t.Errorf("Normalize error = %v, want ErrAliasProfanity", err)
+	}
+	if _, err := p.Normalize("comment"); err != nil {
+		t.Errorf("Normalize(%q): %v", "comment", err)
+	}
+}
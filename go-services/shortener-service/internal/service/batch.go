@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+	"shortener-service/internal/types"
+)
+
+var (
+	ErrBatchEmpty    = errors.New("batch is empty")
+	ErrBatchTooLarge = errors.New("batch too large")
+	ErrBatchAborted  = errors.New("batch aborted because another item failed")
+)
+
+const (
+	// 单次批量创建的最大条数
+	maxBatchSize = 100
+	// 批量创建时并发校验的条数
+	batchConcurrency = 8
+)
+
+// BatchOutcome 批量创建中单条的结果，Err 非空表示失败
+type BatchOutcome struct {
+	Response *types.ShortenResponse
+	Err      error
+}
+
+// batchItem 批量创建中的单条
+type batchItem struct {
+	req    *types.ShortenRequest
+	link   *model.ShortLink
+	reused bool // 去重命中已有短链接，无需写入
+	err    error
+}
+
+// BatchCreateShortLinks 批量创建短链接
+// 先并发校验每一条，再用多行INSERT一次写入，冲突时逐条写入定位冲突项；
+// atomic 模式下在事务中写入，任一条失败则全部不创建。结果与请求顺序一致
+func (s *shortenerService) BatchCreateShortLinks(ctx context.Context, req *types.BatchShortenRequest) ([]BatchOutcome, error) {
+	reqs := make([]*types.ShortenRequest, 0, len(req.Items)+len(req.URLs))
+	for i := range req.Items {
+		reqs = append(reqs, &req.Items[i])
+	}
+	for _, url := range req.URLs {
+		reqs = append(reqs, &types.ShortenRequest{OriginalURL: url})
+	}
+	if len(reqs) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(reqs) > maxBatchSize {
+		return nil, fmt.Errorf("%w: maximum is %d items", ErrBatchTooLarge, maxBatchSize)
+	}
+
+	items := s.prepareBatch(ctx, reqs, ownerOf(ctx))
+
+	var pending []*batchItem
+	failed := false
+	for _, item := range items {
+		switch {
+		case item.err != nil:
+			failed = true
+		case !item.reused:
+			pending = append(pending, item)
+		}
+	}
+
+	if req.Atomic {
+		if failed {
+			abortBatch(items, ErrBatchAborted)
+		} else {
+			err := s.dbRepo.Transaction(ctx, func(txRepo repo.ShortLinkRepo) error {
+				s.insertBatch(ctx, txRepo, pending)
+				for _, item := range pending {
+					if item.err != nil {
+						return ErrBatchAborted
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				abortBatch(items, err)
+			}
+		}
+	} else {
+		s.insertBatch(ctx, s.dbRepo, pending)
+	}
+
+	outcomes := make([]BatchOutcome, len(items))
+	for i, item := range items {
+		if item.err != nil {
+			outcomes[i].Err = item.err
+			continue
+		}
+		if !item.reused {
+			_ = s.redisRepo.SetShortLink(ctx, item.link, s.cacheTTL)
+		}
+		outcomes[i].Response = s.buildResponse(item.link)
+	}
+	return outcomes, nil
+}
+
+// prepareBatch 有限并发地校验每一条，并检查本批内重复的自定义短链码
+func (s *shortenerService) prepareBatch(ctx context.Context, reqs []*types.ShortenRequest, owner *uint64) []*batchItem {
+	items := make([]*batchItem, len(reqs))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *types.ShortenRequest) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			link, reused, err := s.prepareLink(ctx, req, owner)
+			items[i] = &batchItem{req: req, link: link, reused: reused, err: err}
+		}(i, req)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.err != nil || item.reused || item.req.CustomCode == "" {
+			continue
+		}
+		if seen[item.link.ShortCode] {
+			item.err = ErrShortCodeExists
+			continue
+		}
+		seen[item.link.ShortCode] = true
+	}
+
+	return items
+}
+
+// insertBatch 为自动生成的条目分配短链码后用一条多行INSERT写入
+// 唯一索引冲突时整条语句不生效，改为逐条写入，冲突的条目单独失败，自动生成的短链码重新生成
+func (s *shortenerService) insertBatch(ctx context.Context, r repo.ShortLinkRepo, items []*batchItem) {
+	links := make([]*model.ShortLink, 0, len(items))
+	for _, item := range items {
+		if item.req.CustomCode == "" {
+			code, err := s.idGen.GenerateShortCode()
+			if err != nil {
+				item.err = fmt.Errorf("failed to generate short code: %w", err)
+				continue
+			}
+			item.link.ShortCode = code
+		}
+		links = append(links, item.link)
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	err := r.CreateBatch(ctx, links)
+	if err == nil {
+		if s.codes != nil {
+			for _, link := range links {
+				s.codes.Add(ctx, link.ShortCode)
+			}
+		}
+		return
+	}
+
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		for _, item := range items {
+			if item.err == nil {
+				item.err = fmt.Errorf("failed to create short link: %w", err)
+			}
+		}
+		return
+	}
+
+	for _, item := range items {
+		if item.err == nil {
+			item.err = s.createLink(ctx, r, item.link, item.req.CustomCode != "")
+		}
+	}
+}
+
+// abortBatch 整批放弃时，把尚未失败的条目标记为 reason
+func abortBatch(items []*batchItem, reason error) {
+	for _, item := range items {
+		if item.err == nil {
+			item.err = reason
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+	"shortener-service/internal/types"
+)
+
+// fakeLinkRepo 内存短链表，短链码唯一；只实现批量创建用到的方法
+type fakeLinkRepo struct {
+	repo.ShortLinkRepo
+	links map[string]*model.ShortLink
+}
+
+func (r *fakeLinkRepo) Create(ctx context.Context, link *model.ShortLink) error {
+	if _, ok := r.links[link.ShortCode]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	r.links[link.ShortCode] = link
+	return nil
+}
+
+func (r *fakeLinkRepo) CreateBatch(ctx context.Context, links []*model.ShortLink) error {
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		if _, ok := r.links[link.ShortCode]; ok || seen[link.ShortCode] {
+			return gorm.ErrDuplicatedKey
+		}
+		seen[link.ShortCode] = true
+	}
+	for _, link := range links {
+		r.links[link.ShortCode] = link
+	}
+	return nil
+}
+
+// Transaction 在副本上执行，fn 返回错误时丢弃副本
+func (r *fakeLinkRepo) Transaction(ctx context.Context, fn func(txRepo repo.ShortLinkRepo) error) error {
+	tx := &fakeLinkRepo{links: make(map[string]*model.ShortLink, len(r.links))}
+	for code, link := range r.links {
+		tx.links[code] = link
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	r.links = tx.links
+	return nil
+}
+
+// fakeCacheRepo 只实现批量创建用到的缓存方法
+type fakeCacheRepo struct {
+	repo.RedisRepo
+	cached map[string]bool
+}
+
+func (r *fakeCacheRepo) SetShortLink(ctx context.Context, link *model.ShortLink, ttl time.Duration) error {
+	r.cached[link.ShortCode] = true
+	return nil
+}
+
+func (r *fakeCacheRepo) Exists(ctx context.Context, code string) (bool, error) {
+	return false, nil
+}
+
+// seqIDGen 按顺序生成短链码
+type seqIDGen struct {
+	next int64
+}
+
+func (g *seqIDGen) GenerateID() (int64, error) {
+	g.next++
+	return g.next, nil
+}
+
+func (g *seqIDGen) GenerateShortCode() (string, error) {
+	id, _ := g.GenerateID()
+	return fmt.Sprintf("gen%d", id), nil
+}
+
+func newBatchTestService(t *testing.T, existing ...string) (*shortenerService, *fakeLinkRepo, *fakeCacheRepo) {
+	t.Helper()
+
+	aliases, err := NewAliasPolicy(testAliasCharset, 4, 20, nil, nil, CaseFoldingPreserve)
+	if err != nil {
+		t.Fatalf("NewAliasPolicy: %v", err)
+	}
+	links := &fakeLinkRepo{links: make(map[string]*model.ShortLink)}
+	for _, code := range existing {
+		links.links[code] = &model.ShortLink{ShortCode: code}
+	}
+	cache := &fakeCacheRepo{cached: make(map[string]bool)}
+
+	svc := NewShortenerService(links, cache, nil, &seqIDGen{}, NewURLNormalizer([]string{"http", "https"}, 2048),
+		nil, aliases, nil, "http://s.test", 3600, 60)
+	return svc.(*shortenerService), links, cache
+}
+
+func TestBatchCreateShortLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		req      types.BatchShortenRequest
+		wantErrs []error // 与请求顺序一致，nil 表示成功
+		wantRows int     // 结束后表中的行数（含 existing）
+	}{
+		{
+			name: "partial all valid",
+			req: types.BatchShortenRequest{
+				Items: []types.ShortenRequest{{OriginalURL: "https://a.example/", CustomCode: "mine"}},
+				URLs:  []string{"https://b.example/", "https://c.example/"},
+			},
+			wantErrs: []error{nil, nil, nil},
+			wantRows: 3,
+		},
+		{
+			name: "partial keeps valid items",
+			req: types.BatchShortenRequest{Items: []types.ShortenRequest{
+				{OriginalURL: "https://a.example/"},
+				{OriginalURL: "ftp://b.example/"},
+				{OriginalURL: "https://c.example/", CustomCode: "dup1"},
+				{OriginalURL: "https://d.example/", CustomCode: "dup1"},
+				{OriginalURL: "https://e.example/", CustomCode: "x"},
+			}},
+			wantErrs: []error{nil, ErrSchemeNotAllowed, nil, ErrShortCodeExists, ErrAliasTooShort},
+			wantRows: 2,
+		},
+		{
+			name:     "partial insert conflict fails only that item",
+			existing: []string{"taken"},
+			req: types.BatchShortenRequest{Items: []types.ShortenRequest{
+				{OriginalURL: "https://a.example/"},
+				{OriginalURL: "https://b.example/", CustomCode: "taken"},
+				{OriginalURL: "https://c.example/", CustomCode: "free"},
+			}},
+			wantErrs: []error{nil, ErrShortCodeExists, nil},
+			wantRows: 3,
+		},
+		{
+			name: "atomic all valid",
+			req: types.BatchShortenRequest{Atomic: true, URLs: []string{
+				"https://a.example/", "https://b.example/",
+			}},
+			wantErrs: []error{nil, nil},
+			wantRows: 2,
+		},
+		{
+			name: "atomic validation failure aborts all",
+			req: types.BatchShortenRequest{Atomic: true, Items: []types.ShortenRequest{
+				{OriginalURL: "https://a.example/"},
+				{OriginalURL: "not a url"},
+				{OriginalURL: "https://c.example/", CustomCode: "mine"},
+			}},
+			wantErrs: []error{ErrBatchAborted, ErrURLInvalid, ErrBatchAborted},
+			wantRows: 0,
+		},
+		{
+			name:     "atomic insert conflict rolls back",
+			existing: []string{"taken"},
+			req: types.BatchShortenRequest{Atomic: true, Items: []types.ShortenRequest{
+				{OriginalURL: "https://a.example/"},
+				{OriginalURL: "https://b.example/", CustomCode: "taken"},
+				{OriginalURL: "https://c.example/", CustomCode: "free"},
+			}},
+			wantErrs: []error{ErrBatchAborted, ErrShortCodeExists, ErrBatchAborted},
+			wantRows: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, links, cache := newBatchTestService(t, tt.existing...)
+
+			outcomes, err := svc.BatchCreateShortLinks(context.Background(), &tt.req)
+			if err != nil {
+				t.Fatalf("BatchCreateShortLinks: %v", err)
+			}
+			if len(outcomes) != len(tt.wantErrs) {
+				t.Fatalf("got %d outcomes, want %d", len(outcomes), len(tt.wantErrs))
+			}
+
+			for i, outcome := range outcomes {
+				want := tt.wantErrs[i]
+				if want == nil {
+					if outcome.Err != nil || outcome.Response == nil {
+						t.Errorf("item %d: err = %v, want success", i, outcome.Err)
+						continue
+					}
+					code := outcome.Response.ShortCode
+					if _, ok := links.links[code]; !ok {
+						t.Errorf("item %d: %q not stored", i, code)
+					}
+					if !cache.cached[code] {
+						t.Errorf("item %d: %q not cached", i, code)
+					}
+					if outcome.Response.ShortURL != "http://s.test/"+code {
+						t.Errorf("item %d: short url = %q", i, outcome.Response.ShortURL)
+					}
+					continue
+				}
+				if !errors.Is(outcome.Err, want) {
+					t.Errorf("item %d: err = %v, want %v", i, outcome.Err, want)
+				}
+				if outcome.Response != nil {
+					t.Errorf("item %d: failed item has a response", i)
+				}
+			}
+
+			if len(links.links) != tt.wantRows {
+				t.Errorf("stored %d rows, want %d", len(links.links), tt.wantRows)
+			}
+			if tt.req.Atomic && tt.wantRows == len(tt.existing) && len(cache.cached) != 0 {
+				t.Errorf("aborted atomic batch cached %d links", len(cache.cached))
+			}
+		})
+	}
+}
+
+func TestBatchCreateShortLinksLimits(t *testing.T) {
+	tooMany := make([]string, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("https://example.com/%d", i)
+	}
+
+	tests := []struct {
+		name string
+		req  types.BatchShortenRequest
+		want error
+	}{
+		{name: "empty", req: types.BatchShortenRequest{}, want: ErrBatchEmpty},
+		{name: "too large", req: types.BatchShortenRequest{URLs: tooMany}, want: ErrBatchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, links, _ := newBatchTestService(t)
+			if _, err := svc.BatchCreateShortLinks(context.Background(), &tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if len(links.links) != 0 {
+				t.Errorf("stored %d rows, want 0", len(links.links))
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+	"shortener-service/internal/types"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignInvalid  = errors.New("invalid campaign")
+	ErrCampaignExists   = errors.New("campaign name already exists")
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrTagInvalid       = errors.New("invalid tag")
+	ErrTagExists        = errors.New("tag name already exists")
+)
+
+const (
+	// 活动名称和描述的最大长度(字符)
+	maxCampaignNameLength        = 100
+	maxCampaignDescriptionLength = 500
+	// 标签名的最大长度(字符)
+	maxTagLength = 50
+	// 单个短链接的最大标签数
+	maxTagsPerLink = 20
+	// 活动详情合并未落库访问计数时的短链接数量上限
+	campaignPendingLinkLimit = 1000
+)
+
+// CampaignService 活动和标签服务接口
+type CampaignService interface {
+	CreateCampaign(ctx context.Context, req *types.CampaignRequest) (*types.CampaignResponse, error)
+	GetCampaign(ctx context.Context, id uint64) (*types.CampaignResponse, error)
+	ListCampaigns(ctx context.Context) ([]*types.CampaignResponse, error)
+	UpdateCampaign(ctx context.Context, id uint64, req *types.CampaignRequest) (*types.CampaignResponse, error)
+	DeleteCampaign(ctx context.Context, id uint64) error
+
+	CreateTag(ctx context.Context, req *types.TagRequest) (*types.TagResponse, error)
+	ListTags(ctx context.Context) ([]*types.TagResponse, error)
+	UpdateTag(ctx context.Context, id uint64, req *types.TagRequest) (*types.TagResponse, error)
+	DeleteTag(ctx context.Context, id uint64) error
+}
+
+// campaignService 活动和标签服务实现
+type campaignService struct {
+	campaigns repo.CampaignRepo
+	redisRepo repo.RedisRepo
+}
+
+// NewCampaignService 创建活动和标签服务实例
+func NewCampaignService(campaigns repo.CampaignRepo, redisRepo repo.RedisRepo) CampaignService {
+	return &campaignService{campaigns: campaigns, redisRepo: redisRepo}
+}
+
+// CreateCampaign 创建活动
+func (s *campaignService) CreateCampaign(ctx context.Context, req *types.CampaignRequest) (*types.CampaignResponse, error) {
+	campaign := &model.Campaign{UserID: ownerOf(ctx)}
+	if req.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", ErrCampaignInvalid)
+	}
+	if err := applyCampaignRequest(campaign, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.campaigns.CreateCampaign(ctx, campaign); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCampaignExists
+		}
+		return nil, fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	return buildCampaignResponse(campaign, nil), nil
+}
+
+// GetCampaign 获取活动详情和汇总数据，访问次数包含尚未合并到数据库的计数
+func (s *campaignService) GetCampaign(ctx context.Context, id uint64) (*types.CampaignResponse, error) {
+	campaign, err := s.ownedCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.campaigns.GetCampaignStats(ctx, []uint64{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+	if stat := stats[id]; stat != nil {
+		s.addPendingVisits(ctx, stat)
+	}
+
+	return buildCampaignResponse(campaign, stats[id]), nil
+}
+
+// ListCampaigns 查询调用方的活动列表和汇总数据，访问次数只含已落库的部分
+func (s *campaignService) ListCampaigns(ctx context.Context) ([]*types.CampaignResponse, error) {
+	campaigns, err := s.campaigns.ListCampaigns(ctx, ownerOf(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+
+	ids := make([]uint64, len(campaigns))
+	for i, campaign := range campaigns {
+		ids[i] = campaign.ID
+	}
+	stats, err := s.campaigns.GetCampaignStats(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+
+	resp := make([]*types.CampaignResponse, len(campaigns))
+	for i, campaign := range campaigns {
+		resp[i] = buildCampaignResponse(campaign, stats[campaign.ID])
+	}
+	return resp, nil
+}
+
+// UpdateCampaign 更新活动名称或描述
+func (s *campaignService) UpdateCampaign(ctx context.Context, id uint64, req *types.CampaignRequest) (*types.CampaignResponse, error) {
+	campaign, err := s.ownedCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyCampaignRequest(campaign, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.campaigns.UpdateCampaign(ctx, campaign); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCampaignExists
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to update campaign: %w", err)
+	}
+
+	return s.GetCampaign(ctx, id)
+}
+
+// DeleteCampaign 删除活动，活动下的短链接保留并移出活动
+func (s *campaignService) DeleteCampaign(ctx context.Context, id uint64) error {
+	if _, err := s.ownedCampaign(ctx, id); err != nil {
+		return err
+	}
+
+	codes, err := s.campaigns.DeleteCampaign(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCampaignNotFound
+		}
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+
+	// 缓存中的短链接仍带有旧的活动ID
+	for _, code := range codes {
+		_ = s.redisRepo.DeleteShortLink(ctx, &model.ShortLink{ShortCode: code})
+	}
+	return nil
+}
+
+// CreateTag 创建标签
+func (s *campaignService) CreateTag(ctx context.Context, req *types.TagRequest) (*types.TagResponse, error) {
+	name, err := normalizeTag(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &model.Tag{UserID: ownerOf(ctx), Name: name}
+	if err := s.campaigns.CreateTag(ctx, tag); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrTagExists
+		}
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	return buildTagResponse(tag, 0), nil
+}
+
+// ListTags 查询调用方的标签列表和每个标签的短链接数量
+func (s *campaignService) ListTags(ctx context.Context) ([]*types.TagResponse, error) {
+	tags, err := s.campaigns.ListTags(ctx, ownerOf(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	ids := make([]uint64, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	counts, err := s.campaigns.CountTagLinks(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag links: %w", err)
+	}
+
+	resp := make([]*types.TagResponse, len(tags))
+	for i, tag := range tags {
+		resp[i] = buildTagResponse(tag, counts[tag.ID])
+	}
+	return resp, nil
+}
+
+// UpdateTag 重命名标签
+func (s *campaignService) UpdateTag(ctx context.Context, id uint64, req *types.TagRequest) (*types.TagResponse, error) {
+	tag, err := s.ownedTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.Name, err = normalizeTag(req.Name); err != nil {
+		return nil, err
+	}
+
+	if err := s.campaigns.UpdateTag(ctx, tag); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrTagExists
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	counts, err := s.campaigns.CountTagLinks(ctx, []uint64{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag links: %w", err)
+	}
+	return buildTagResponse(tag, counts[id]), nil
+}
+
+// DeleteTag 删除标签，同时移除其与短链接的关联
+func (s *campaignService) DeleteTag(ctx context.Context, id uint64) error {
+	if _, err := s.ownedTag(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.campaigns.DeleteTag(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTagNotFound
+		}
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// ownedCampaign 查询调用方有权访问的活动，越权访问按不存在处理
+func (s *campaignService) ownedCampaign(ctx context.Context, id uint64) (*model.Campaign, error) {
+	campaign, err := s.campaigns.GetCampaign(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+	if !ownedBy(ctx, campaign.UserID) {
+		return nil, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// ownedTag 查询调用方有权访问的标签，越权访问按不存在处理
+func (s *campaignService) ownedTag(ctx context.Context, id uint64) (*model.Tag, error) {
+	tag, err := s.campaigns.GetTag(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+	if !ownedBy(ctx, tag.UserID) {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+// ownerCampaign 查询短链接要归入的活动，活动必须与短链接属于同一创建者
+func ownerCampaign(ctx context.Context, campaigns repo.CampaignRepo, id uint64, owner *uint64) (*model.Campaign, error) {
+	campaign, err := campaigns.GetCampaign(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+	if !sameOwner(campaign.UserID, owner) {
+		return nil, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// resolveTags 把标签名解析为创建者的标签ID，不存在的标签自动创建
+func resolveTags(ctx context.Context, campaigns repo.CampaignRepo, owner *uint64, names []string) ([]uint64, error) {
+	names, err := normalizeTags(names)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	tags, err := campaigns.FindTagsByName(ctx, owner, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tags: %w", err)
+	}
+	found := make(map[string]uint64, len(tags))
+	for _, tag := range tags {
+		found[strings.ToLower(tag.Name)] = tag.ID
+	}
+
+	ids := make([]uint64, 0, len(names))
+	for _, name := range names {
+		if id, ok := found[strings.ToLower(name)]; ok {
+			ids = append(ids, id)
+			continue
+		}
+
+		tag := &model.Tag{UserID: owner, Name: name}
+		if err := campaigns.CreateTag(ctx, tag); err != nil {
+			if !errors.Is(err, gorm.ErrDuplicatedKey) {
+				return nil, fmt.Errorf("failed to create tag: %w", err)
+			}
+			// 并发创建了同名标签，重新查询
+			existing, err := campaigns.FindTagsByName(ctx, owner, []string{name})
+			if err != nil {
+				return nil, fmt.Errorf("failed to find tags: %w", err)
+			}
+			if len(existing) == 0 {
+				return nil, fmt.Errorf("failed to create tag %q: %w", name, gorm.ErrDuplicatedKey)
+			}
+			tag = existing[0]
+		}
+		ids = append(ids, tag.ID)
+	}
+	return ids, nil
+}
+
+// normalizeTags 去掉空白和重复的标签名（不区分大小写）并校验数量
+func normalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, raw := range names {
+		name, err := normalizeTag(raw)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, name)
+	}
+	if len(result) > maxTagsPerLink {
+		return nil, fmt.Errorf("%w: at most %d tags per link", ErrTagInvalid, maxTagsPerLink)
+	}
+	return result, nil
+}
+
+// normalizeTag 去掉首尾空白并校验标签名
+func normalizeTag(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrTagInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxTagLength {
+		return "", fmt.Errorf("%w: name must be at most %d characters", ErrTagInvalid, maxTagLength)
+	}
+	return name, nil
+}
+
+// applyCampaignRequest 把请求中的非空字段写入活动并校验
+func applyCampaignRequest(campaign *model.Campaign, req *types.CampaignRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name is required", ErrCampaignInvalid)
+		}
+		if utf8.RuneCountInString(name) > maxCampaignNameLength {
+			return fmt.Errorf("%w: name must be at most %d characters", ErrCampaignInvalid, maxCampaignNameLength)
+		}
+		campaign.Name = name
+	}
+	if req.Description != nil {
+		if utf8.RuneCountInString(*req.Description) > maxCampaignDescriptionLength {
+			return fmt.Errorf("%w: description must be at most %d characters", ErrCampaignInvalid, maxCampaignDescriptionLength)
+		}
+		campaign.Description = *req.Description
+	}
+	if req.UTM != nil {
+		utm, err := parseUTM(req.UTM)
+		if err != nil {
+			return err
+		}
+		campaign.UTM = utm
+	}
+	return nil
+}
+
+// addPendingVisits 把活动下短链接尚未合并到数据库的访问计数累加到汇总数据上
+// 需要逐个读取短链接的计数，短链接超过 campaignPendingLinkLimit 时只返回已落库的部分；
+// 读取失败时同样只返回已落库的部分
+func (s *campaignService) addPendingVisits(ctx context.Context, stat *model.CampaignStats) {
+	if stat.LinkCount > campaignPendingLinkLimit {
+		return
+	}
+	campaignCodes, err := s.campaigns.ListCampaignCodes(ctx, []uint64{stat.CampaignID})
+	if err != nil {
+		log.Printf("Failed to list links of campaign %d: %v", stat.CampaignID, err)
+		return
+	}
+	codes := campaignCodes[stat.CampaignID]
+	pending, err := s.redisRepo.GetPendingVisits(ctx, codes)
+	if err != nil {
+		log.Printf("Failed to get pending visits of campaign %d: %v", stat.CampaignID, err)
+		return
+	}
+
+	var delta repo.VisitDelta
+	for _, code := range codes {
+		delta.Merge(pending[code])
+	}
+	stat.VisitCount += delta.Count
+	if !delta.LastVisitedAt.IsZero() &&
+		(stat.LastVisitedAt == nil || delta.LastVisitedAt.After(*stat.LastVisitedAt)) {
+		last := delta.LastVisitedAt
+		stat.LastVisitedAt = &last
+	}
+}
+
+// buildCampaignResponse 构建活动响应，stats 为空表示活动下没有短链接
+func buildCampaignResponse(campaign *model.Campaign, stats *model.CampaignStats) *types.CampaignResponse {
+	resp := &types.CampaignResponse{
+		ID:          campaign.ID,
+		Name:        campaign.Name,
+		Description: campaign.Description,
+		UTM:         utmParams(campaign.UTM),
+		CreatedAt:   campaign.CreatedAt,
+		UpdatedAt:   campaign.UpdatedAt,
+	}
+	if stats != nil {
+		resp.LinkCount = stats.LinkCount
+		resp.VisitCount = stats.VisitCount
+		resp.LastVisitedAt = stats.LastVisitedAt
+	}
+	return resp
+}
+
+// buildTagResponse 构建标签响应
+func buildTagResponse(tag *model.Tag, linkCount uint64) *types.TagResponse {
+	return &types.TagResponse{
+		ID:        tag.ID,
+		Name:      tag.Name,
+		LinkCount: linkCount,
+		CreatedAt: tag.CreatedAt,
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"shortener-service/internal/repo"
+)
+
+const (
+	// 重建时每批扫描的行数
+	codeFilterScanBatch = 1000
+	// 重建锁有效期，超过后其他实例可以重新发起重建
+	codeFilterLockTTL = 10 * time.Minute
+	// 写入位图失败后触发的重建被跳过或失败时，重试的间隔
+	codeFilterRetryDelay = time.Minute
+)
+
+// CodeFilter 短链码布隆过滤器（Redis位图）
+// 查库前先检查，判定不存在的短链码直接返回，避免机器人扫描随机短链码打到数据库；
+// 位图不存在时一律视为可能存在，并定期从 short_links 重建以清除已删除的短链码；
+// 新短链码写入位图失败时，本实例在下一次重建完成前对其放行，并立即触发重建，其他实例在重建完成后恢复
+type CodeFilter struct {
+	redisRepo repo.RedisRepo
+	dbRepo    repo.ShortLinkRepo
+	bits      uint64 // 位图大小
+	hashes    int    // 哈希函数个数
+	interval  time.Duration
+	rebuildCh chan struct{}
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+
+	mu     sync.Mutex
+	missed map[string]time.Time // 写入位图失败的短链码及失败时间
+}
+
+// NewCodeFilter 按预期短链数量和误判率创建布隆过滤器
+func NewCodeFilter(
+	redisRepo repo.RedisRepo,
+	dbRepo repo.ShortLinkRepo,
+	expectedItems int,
+	falsePositiveRate float64,
+	rebuildInterval int,
+) (*CodeFilter, error) {
+	if expectedItems <= 0 {
+		return nil, fmt.Errorf("invalid bloom filter expected items: %d", expectedItems)
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("invalid bloom filter false positive rate: %v", falsePositiveRate)
+	}
+
+	// m = -n*ln(p)/(ln2)^2, k = m/n*ln2
+	bits := uint64(math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if bits > math.MaxUint32 {
+		return nil, fmt.Errorf("bloom filter too large: %d bits exceeds redis bitmap limit", bits)
+	}
+	hashes := int(math.Round(float64(bits) / float64(expectedItems) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &CodeFilter{
+		redisRepo: redisRepo,
+		dbRepo:    dbRepo,
+		bits:      bits,
+		hashes:    hashes,
+		interval:  time.Duration(rebuildInterval) * time.Second,
+		rebuildCh: make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		missed:    make(map[string]time.Time),
+	}, nil
+}
+
+// MightExist 短链码是否可能存在，Redis出错时视为可能存在
+func (f *CodeFilter) MightExist(ctx context.Context, code string) bool {
+	f.mu.Lock()
+	_, missed := f.missed[code]
+	f.mu.Unlock()
+	if missed {
+		return true
+	}
+
+	ok, err := f.redisRepo.BloomTest(ctx, f.offsets(code))
+	if err != nil {
+		return true
+	}
+	return ok
+}
+
+// Add 记录新创建的短链码
+// 写入失败时记录该短链码并触发重建，避免已入库的短链在重建前被判定为不存在
+func (f *CodeFilter) Add(ctx context.Context, code string) {
+	err := f.redisRepo.BloomAdd(ctx, f.offsets(code))
+	if err == nil {
+		return
+	}
+
+	log.Printf("Failed to add %s to short code bloom filter, scheduling rebuild: %v", code, err)
+	f.mu.Lock()
+	f.missed[code] = time.Now()
+	f.mu.Unlock()
+	f.requestRebuild()
+}
+
+// Start 立即重建一次（位图尚未构建时即可生效），之后按间隔定期重建，写入位图失败时提前重建
+func (f *CodeFilter) Start() {
+	go func() {
+		f.rebuildAndLog()
+
+		var tick <-chan time.Time
+		if f.interval > 0 {
+			ticker := time.NewTicker(f.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-tick:
+				f.rebuildAndLog()
+			case <-f.rebuildCh:
+				f.rebuildAndLog()
+			case <-f.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期重建
+func (f *CodeFilter) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stopCh)
+	})
+}
+
+// rebuildAndLog 重建并记录结果
+// 重建从数据库扫描，开始前写入位图失败的短链码已包含在新位图中，不再需要放行；
+// 重建失败或被其他实例抢先时，仍有未补齐的短链码则稍后重试
+func (f *CodeFilter) rebuildAndLog() {
+	started := time.Now()
+	count, err := f.Rebuild(context.Background())
+	if err != nil {
+		log.Printf("Failed to rebuild short code bloom filter: %v", err)
+	}
+	if err != nil || count < 0 {
+		if f.hasMissed() {
+			time.AfterFunc(codeFilterRetryDelay, f.requestRebuild)
+		}
+		return
+	}
+
+	log.Printf("Short code bloom filter rebuilt: %d codes, %d bits, %d hashes", count, f.bits, f.hashes)
+	f.mu.Lock()
+	for code, at := range f.missed {
+		if at.Before(started) {
+			delete(f.missed, code)
+		}
+	}
+	f.mu.Unlock()
+}
+
+// requestRebuild 请求后台协程尽快重建，已有待处理的请求时忽略
+func (f *CodeFilter) requestRebuild() {
+	select {
+	case f.rebuildCh <- struct{}{}:
+	default:
+	}
+}
+
+// hasMissed 是否有写入位图失败且尚未重建的短链码
+func (f *CodeFilter) hasMissed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.missed) > 0
+}
+
+// Rebuild 从数据库全量重建位图，其他实例正在重建时跳过并返回-1
+func (f *CodeFilter) Rebuild(ctx context.Context) (int, error) {
+	ok, err := f.redisRepo.BloomBeginRebuild(ctx, f.bits, codeFilterLockTTL)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return -1, nil
+	}
+
+	count, err := f.scan(ctx)
+	if err != nil {
+		_ = f.redisRepo.BloomAbortRebuild(ctx)
+		return 0, err
+	}
+	if err := f.redisRepo.BloomCommitRebuild(ctx); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// scan 扫描全部未删除的短链码写入重建中的位图
+func (f *CodeFilter) scan(ctx context.Context) (int, error) {
+	var afterID uint64
+	count := 0
+
+	for {
+		links, err := f.dbRepo.ListCodesAfter(ctx, afterID, codeFilterScanBatch)
+		if err != nil {
+			return 0, err
+		}
+		if len(links) == 0 {
+			return count, nil
+		}
+		afterID = links[len(links)-1].ID
+
+		offsets := make([]uint64, 0, len(links)*f.hashes)
+		for _, link := range links {
+			offsets = append(offsets, f.offsets(link.ShortCode)...)
+		}
+		if err := f.redisRepo.BloomAddRebuild(ctx, offsets); err != nil {
+			return 0, err
+		}
+		count += len(links)
+	}
+}
+
+// offsets 计算短链码在位图中的k个位置（双重哈希）
+func (f *CodeFilter) offsets(code string) []uint64 {
+	sum := sha256.Sum256([]byte(code))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	offsets := make([]uint64, f.hashes)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return offsets
+}
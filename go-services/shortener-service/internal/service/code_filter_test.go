@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"shortener-service/internal/repo"
+)
+
+// fakeBloomRedis 内存位图，只实现布隆过滤器用到的方法
+type fakeBloomRedis struct {
+	repo.RedisRepo
+	bits   map[uint64]bool
+	err    error
+	addErr error
+}
+
+func (r *fakeBloomRedis) BloomAdd(ctx context.Context, offsets []uint64) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.addErr != nil {
+		return r.addErr
+	}
+	for _, off := range offsets {
+		r.bits[off] = true
+	}
+	return nil
+}
+
+func (r *fakeBloomRedis) BloomTest(ctx context.Context, offsets []uint64) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	for _, off := range offsets {
+		if !r.bits[off] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func TestNewCodeFilterSizing(t *testing.T) {
+	tests := []struct {
+		name              string
+		expectedItems     int
+		falsePositiveRate float64
+		wantBits          uint64
+		wantHashes        int
+	}{
+		{name: "default config", expectedItems: 1000000, falsePositiveRate: 0.001, wantBits: 14377588, wantHashes: 10},
+		{name: "one percent", expectedItems: 1000, falsePositiveRate: 0.01, wantBits: 9586, wantHashes: 7},
+		{name: "loose rate keeps one hash", expectedItems: 100, falsePositiveRate: 0.9, wantBits: 22, wantHashes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewCodeFilter(nil, nil, tt.expectedItems, tt.falsePositiveRate, 0)
+			if err != nil {
+				t.Fatalf("NewCodeFilter: %v", err)
+			}
+			if f.bits != tt.wantBits || f.hashes != tt.wantHashes {
+				t.Errorf("bits, hashes = %d, %d, want %d, %d", f.bits, f.hashes, tt.wantBits, tt.wantHashes)
+			}
+		})
+	}
+}
+
+func TestNewCodeFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name              string
+		expectedItems     int
+		falsePositiveRate float64
+	}{
+		{name: "zero items", expectedItems: 0, falsePositiveRate: 0.01},
+		{name: "zero rate", expectedItems: 1000, falsePositiveRate: 0},
+		{name: "rate of one", expectedItems: 1000, falsePositiveRate: 1},
+		{name: "exceeds redis bitmap", expectedItems: 1 << 30, falsePositiveRate: 0.0001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCodeFilter(nil, nil, tt.expectedItems, tt.falsePositiveRate, 0); err == nil {
+				t.Error("NewCodeFilter succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestCodeFilterOffsets(t *testing.T) {
+	f, err := NewCodeFilter(nil, nil, 1000, 0.01, 0)
+	if err != nil {
+		t.Fatalf("NewCodeFilter: %v", err)
+	}
+
+	tests := []string{"a", "aBc123", "my-custom-alias", "链接"}
+	for _, code := range tests {
+		t.Run(code, func(t *testing.T) {
+			offsets := f.offsets(code)
+			if len(offsets) != f.hashes {
+				t.Fatalf("len(offsets) = %d, want %d", len(offsets), f.hashes)
+			}
+			seen := make(map[uint64]bool, len(offsets))
+			for i, off := range offsets {
+				if off >= f.bits {
+					t.Errorf("offsets[%d] = %d, out of range %d", i, off, f.bits)
+				}
+				seen[off] = true
+				if again := f.offsets(code); again[i] != off {
+					t.Errorf("offsets[%d] not deterministic: %d then %d", i, off, again[i])
+				}
+			}
+			if len(seen) < 2 {
+				t.Errorf("offsets collapse to %d position", len(seen))
+			}
+		})
+	}
+}
+
+func TestCodeFilterFalsePositiveRate(t *testing.T) {
+	const (
+		items  = 2000
+		probes = 20000
+		rate   = 0.01
+	)
+	redis := &fakeBloomRedis{bits: make(map[uint64]bool)}
+	f, err := NewCodeFilter(redis, nil, items, rate, 0)
+	if err != nil {
+		t.Fatalf("NewCodeFilter: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < items; i++ {
+		f.Add(ctx, fmt.Sprintf("code-%d", i))
+	}
+	for i := 0; i < items; i++ {
+		if code := fmt.Sprintf("code-%d", i); !f.MightExist(ctx, code) {
+			t.Fatalf("MightExist(%q) = false for an added code", code)
+		}
+	}
+
+	positives := 0
+	for i := 0; i < probes; i++ {
+		if f.MightExist(ctx, fmt.Sprintf("missing-%d", i)) {
+			positives++
+		}
+	}
+	if got := float64(positives) / probes; got > 2*rate {
+		t.Errorf("false positive rate = %.4f, want at most %.4f", got, 2*rate)
+	}
+}
+
+func TestCodeFilterMightExistOnRedisError(t *testing.T) {
+	redis := &fakeBloomRedis{bits: make(map[uint64]bool), err: errors.New("connection refused")}
+	f, err := NewCodeFilter(redis, nil, 1000, 0.01, 0)
+	if err != nil {
+		t.Fatalf("NewCodeFilter: %v", err)
+	}
+	if !f.MightExist(context.Background(), "anything") {
+		t.Error("MightExist = false on redis error, want true")
+	}
+}
+
+func TestCodeFilterMightExistAfterFailedAdd(t *testing.T) {
+	redis := &fakeBloomRedis{bits: make(map[uint64]bool), addErr: errors.New("connection reset")}
+	f, err := NewCodeFilter(redis, nil, 1000, 0.01, 0)
+	if err != nil {
+		t.Fatalf("NewCodeFilter: %v", err)
+	}
+
+	ctx := context.Background()
+	f.Add(ctx, "fresh")
+	if !f.MightExist(ctx, "fresh") {
+		t.Error("MightExist = false for a code whose bloom write failed, want true")
+	}
+	if f.MightExist(ctx, "other") {
+		t.Error("MightExist = true for a code never added, want false")
+	}
+	select {
+	case <-f.rebuildCh:
+	default:
+		t.Error("failed Add did not request a rebuild")
+	}
+}
@@ -1,74 +1,498 @@
 package service
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/snowflake"
+
+	"shortener-service/internal/repo"
+)
+
+// 短链码生成策略
+const (
+	IDGenSnowflake = "snowflake" // 雪花算法ID编码，至少 CodeLength 位（64位ID通常编码为11位）
+	IDGenRandom    = "random"    // 固定长度随机码，冲突时由创建流程重试
+	IDGenCounter   = "counter"   // Redis自增计数器编码，不足 CodeLength 位时左侧补齐
+	IDGenSegment   = "segment"   // MySQL号段分配，ID连续紧凑，多实例无需分配机器ID
 )
 
+// DefaultAlphabet 默认短链码字符集(Base62)
+const DefaultAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
 // IDGenerator ID生成器接口
 type IDGenerator interface {
 	GenerateID() (int64, error)
 	GenerateShortCode() (string, error)
 }
 
+// IDGenOptions ID生成器配置
+type IDGenOptions struct {
+	Strategy       string
+	Alphabet       string
+	CodeLength     int
+	MachineID      int64         // 雪花算法机器ID，启用租约时忽略
+	MachineLease   bool          // 从Redis自动租用雪花算法机器ID
+	LeaseTTL       time.Duration // 机器ID租约有效期
+	ObfuscationKey string        // 非空时对ID做带密钥的置换后再编码
+	SegmentBizTag  string        // 号段模式业务标识
+	SegmentStep    int           // 号段模式每次租用的ID数量
+}
+
+// NewIDGenerator 按配置的策略创建ID生成器
+// redisRepo 仅计数器策略和雪花算法机器ID租约使用，segmentRepo 仅号段策略使用，其余情况可为空
+func NewIDGenerator(opts IDGenOptions, redisRepo repo.RedisRepo, segmentRepo repo.SegmentRepo) (IDGenerator, error) {
+	if opts.Alphabet == "" {
+		opts.Alphabet = DefaultAlphabet
+	}
+	if err := validateAlphabet(opts.Alphabet); err != nil {
+		return nil, err
+	}
+	if opts.CodeLength <= 0 {
+		return nil, fmt.Errorf("invalid code length: %d", opts.CodeLength)
+	}
+
+	encoder, err := NewCodeEncoder(opts.Alphabet, opts.CodeLength, opts.ObfuscationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Strategy {
+	case "", IDGenSnowflake:
+		if !opts.MachineLease {
+			return NewSnowflakeIDGen(opts.MachineID, encoder, nil)
+		}
+		if redisRepo == nil {
+			return nil, fmt.Errorf("machine lease requires a redis repo")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		lease, err := AcquireMachineLease(ctx, redisRepo, opts.LeaseTTL)
+		if err != nil {
+			return nil, err
+		}
+		gen, err := NewSnowflakeIDGen(lease.MachineID(), encoder, lease)
+		if err != nil {
+			_ = lease.Release(0)
+			return nil, err
+		}
+		return gen, nil
+	case IDGenRandom:
+		return NewRandomIDGen(opts.Alphabet, opts.CodeLength), nil
+	case IDGenCounter:
+		return NewCounterIDGen(redisRepo, encoder), nil
+	case IDGenSegment:
+		if segmentRepo == nil {
+			return nil, fmt.Errorf("segment strategy requires a segment repo")
+		}
+		return NewSegmentIDGen(segmentRepo, encoder, opts.SegmentBizTag, opts.SegmentStep)
+	default:
+		return nil, fmt.Errorf("unknown id generator strategy: %s", opts.Strategy)
+	}
+}
+
 // SnowflakeIDGen 雪花算法ID生成器
 type SnowflakeIDGen struct {
-	node *snowflake.Node
+	node    *snowflake.Node
+	encoder CodeEncoder
+	lease   *MachineLease // 为空表示使用静态配置的机器ID
+
+	mu         sync.Mutex
+	lastMillis int64 // 最近一次生成的ID时间(毫秒)
 }
 
 // NewSnowflakeIDGen 创建雪花算法ID生成器
-func NewSnowflakeIDGen(machineID int64) (*SnowflakeIDGen, error) {
+// lease 非空时使用租约的机器ID，以上一个持有者最后生成的ID时间作为时钟回拨检查的起点，并启动后台续约，Close 时释放
+func NewSnowflakeIDGen(machineID int64, encoder CodeEncoder, lease *MachineLease) (*SnowflakeIDGen, error) {
 	node, err := snowflake.NewNode(machineID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create snowflake node: %w", err)
 	}
-	return &SnowflakeIDGen{node: node}, nil
+	g := &SnowflakeIDGen{
+		node:    node,
+		encoder: encoder,
+		lease:   lease,
+	}
+	if lease != nil {
+		g.lastMillis = lease.LastTimestamp()
+		lease.Start(g.lastIssued)
+	}
+	return g, nil
 }
 
 // GenerateID 生成唯一ID
+// 租约丢失或ID时间早于上一个ID（时钟回拨）时拒绝生成
 func (g *SnowflakeIDGen) GenerateID() (int64, error) {
-	return g.node.Generate().Int64(), nil
+	if g.lease != nil {
+		if err := g.lease.Valid(); err != nil {
+			return 0, err
+		}
+	}
+
+	// 生成和比较在同一把锁内，否则先生成的ID可能晚于其他协程更新 lastMillis，被误判为时钟回拨
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.node.Generate()
+	millis := id.Time()
+	if millis < g.lastMillis {
+		return 0, fmt.Errorf("%w: by %dms", ErrClockMovedBackwards, g.lastMillis-millis)
+	}
+	g.lastMillis = millis
+
+	return id.Int64(), nil
 }
 
 // GenerateShortCode 生成短链码
 func (g *SnowflakeIDGen) GenerateShortCode() (string, error) {
-	id := g.node.Generate().Int64()
-	return encodeBase62(id), nil
+	id, err := g.GenerateID()
+	if err != nil {
+		return "", err
+	}
+	return g.encoder.Encode(uint64(id)), nil
+}
+
+// Close 释放机器ID租约
+func (g *SnowflakeIDGen) Close() error {
+	if g.lease == nil {
+		return nil
+	}
+	return g.lease.Release(g.lastIssued())
+}
+
+// lastIssued 最近一次生成的ID时间(毫秒)
+func (g *SnowflakeIDGen) lastIssued() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastMillis
+}
+
+// RandomIDGen 固定长度随机码生成器
+type RandomIDGen struct {
+	alphabet   string
+	codeLength int
+}
+
+// NewRandomIDGen 创建随机码生成器
+func NewRandomIDGen(alphabet string, codeLength int) *RandomIDGen {
+	return &RandomIDGen{
+		alphabet:   alphabet,
+		codeLength: codeLength,
+	}
 }
 
-// encodeBase62 将int64编码为Base62字符串
-func encodeBase62(num int64) string {
+// GenerateID 生成随机正整数ID
+func (g *RandomIDGen) GenerateID() (int64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf[:]) >> 1), nil
+}
+
+// GenerateShortCode 生成短链码
+func (g *RandomIDGen) GenerateShortCode() (string, error) {
+	return generateRandomCode(g.alphabet, g.codeLength)
+}
+
+// CounterIDGen 基于Redis自增计数器的ID生成器
+type CounterIDGen struct {
+	redisRepo repo.RedisRepo
+	encoder   CodeEncoder
+}
+
+// NewCounterIDGen 创建计数器ID生成器
+func NewCounterIDGen(redisRepo repo.RedisRepo, encoder CodeEncoder) *CounterIDGen {
+	return &CounterIDGen{
+		redisRepo: redisRepo,
+		encoder:   encoder,
+	}
+}
+
+// GenerateID 生成唯一ID
+func (g *CounterIDGen) GenerateID() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id, err := g.redisRepo.NextID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment id counter: %w", err)
+	}
+	return id, nil
+}
+
+// GenerateShortCode 生成短链码
+func (g *CounterIDGen) GenerateShortCode() (string, error) {
+	id, err := g.GenerateID()
+	if err != nil {
+		return "", err
+	}
+	return g.encoder.Encode(uint64(id)), nil
+}
+
+// CodeEncoder ID与短链码之间的双向编码
+type CodeEncoder interface {
+	Encode(id uint64) string
+	Decode(code string) (uint64, error)
+}
+
+// ErrCodeUndecodable 短链码无法解码为ID（如自定义短链码）
+var ErrCodeUndecodable = errors.New("short code cannot be decoded")
+
+// NewCodeEncoder 创建编码器，key为空时直接编码，否则先做带密钥的置换
+func NewCodeEncoder(alphabet string, codeLength int, key string) (CodeEncoder, error) {
+	plain := &plainEncoder{alphabet: alphabet, codeLength: codeLength}
+	if key == "" {
+		return plain, nil
+	}
+	if len(key) < 16 {
+		return nil, fmt.Errorf("obfuscation key must be at least 16 bytes")
+	}
+	return newObfuscatedEncoder(plain, []byte(key)), nil
+}
+
+// plainEncoder 直接按字符集编码，不足长度左侧补齐
+type plainEncoder struct {
+	alphabet   string
+	codeLength int
+}
+
+// Encode 编码
+func (e *plainEncoder) Encode(id uint64) string {
+	return padCode(encodeBase(id, e.alphabet), e.codeLength, e.alphabet)
+}
+
+// Decode 解码
+func (e *plainEncoder) Decode(code string) (uint64, error) {
+	return decodeBase(code, e.alphabet)
+}
+
+// feistelRounds Feistel 置换轮数
+const feistelRounds = 6
+
+// obfuscatedEncoder 带密钥的双射置换编码器
+//
+// ID空间按编码长度分层：第L层为 [0, base^L)，ID落在能容纳它的最短一层（不短于 CodeLength），
+// 在该层内用 HMAC-SHA256 作轮函数的 Feistel 网络配合循环步进做置换，再编码并补齐为L位。
+// 同层内是双射，不同层的短链码长度不同，因此整体不会冲突；相邻ID的短链码看起来随机，无法枚举。
+type obfuscatedEncoder struct {
+	plain *plainEncoder
+	key   []byte
+	tiers []feistelTier // 按长度递增，最后一层覆盖整个64位空间
+}
+
+// feistelTier 一层置换空间
+type feistelTier struct {
+	length   int    // 编码长度
+	domain   uint64 // 空间大小，0表示整个64位空间
+	halfBits uint   // Feistel 每半的位数
+}
+
+// newObfuscatedEncoder 创建置换编码器
+func newObfuscatedEncoder(plain *plainEncoder, key []byte) *obfuscatedEncoder {
+	e := &obfuscatedEncoder{plain: plain, key: key}
+
+	base := uint64(len(plain.alphabet))
+	domain := uint64(1)
+	for i := 0; i < plain.codeLength-1; i++ {
+		hi, lo := bits.Mul64(domain, base)
+		if hi != 0 {
+			domain = 0
+			break
+		}
+		domain = lo
+	}
+
+	for length := plain.codeLength; ; length++ {
+		if domain != 0 {
+			hi, lo := bits.Mul64(domain, base)
+			if hi != 0 {
+				domain = 0
+			} else {
+				domain = lo
+			}
+		}
+		if domain == 0 {
+			e.tiers = append(e.tiers, feistelTier{length: length, halfBits: 32})
+			return e
+		}
+		halfBits := uint(bits.Len64(domain-1)+1) / 2
+		e.tiers = append(e.tiers, feistelTier{length: length, domain: domain, halfBits: halfBits})
+	}
+}
+
+// Encode 编码
+func (e *obfuscatedEncoder) Encode(id uint64) string {
+	for _, tier := range e.tiers {
+		if tier.domain == 0 || id < tier.domain {
+			return padCode(encodeBase(e.permute(tier, id), e.plain.alphabet), tier.length, e.plain.alphabet)
+		}
+	}
+	// 最后一层覆盖整个64位空间，不会走到这里
+	return e.plain.Encode(id)
+}
+
+// Decode 解码
+func (e *obfuscatedEncoder) Decode(code string) (uint64, error) {
+	value, err := decodeBase(code, e.plain.alphabet)
+	if err != nil {
+		return 0, err
+	}
+	for _, tier := range e.tiers {
+		if tier.length != len(code) {
+			continue
+		}
+		if tier.domain != 0 && value >= tier.domain {
+			return 0, ErrCodeUndecodable
+		}
+		return e.unpermute(tier, value), nil
+	}
+	return 0, ErrCodeUndecodable
+}
+
+// permute 层内置换，结果超出空间时继续置换直到落入空间（循环步进）
+func (e *obfuscatedEncoder) permute(tier feistelTier, v uint64) uint64 {
+	for {
+		v = e.feistel(tier.halfBits, v, false)
+		if tier.domain == 0 || v < tier.domain {
+			return v
+		}
+	}
+}
+
+// unpermute 层内逆置换
+func (e *obfuscatedEncoder) unpermute(tier feistelTier, v uint64) uint64 {
+	for {
+		v = e.feistel(tier.halfBits, v, true)
+		if tier.domain == 0 || v < tier.domain {
+			return v
+		}
+	}
+}
+
+// feistel 平衡 Feistel 网络，inverse 为true时执行逆运算
+func (e *obfuscatedEncoder) feistel(halfBits uint, v uint64, inverse bool) uint64 {
+	mask := uint64(1)<<halfBits - 1
+	left, right := (v>>halfBits)&mask, v&mask
+
+	for i := 0; i < feistelRounds; i++ {
+		if !inverse {
+			left, right = right, left^(e.round(i, right)&mask)
+		} else {
+			round := feistelRounds - 1 - i
+			left, right = right^(e.round(round, left)&mask), left
+		}
+	}
+
+	return left<<halfBits | right
+}
+
+// round 轮函数
+func (e *obfuscatedEncoder) round(i int, v uint64) uint64 {
+	var buf [9]byte
+	buf[0] = byte(i)
+	binary.BigEndian.PutUint64(buf[1:], v)
+
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// validateAlphabet 校验字符集：至少两个字符、无重复、不含URL保留字符
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return fmt.Errorf("alphabet must contain at least 2 characters")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if c > 127 {
+			return fmt.Errorf("alphabet must be ASCII: %q", c)
+		}
+		if strings.ContainsRune(unsafeAliasChars, c) {
+			return fmt.Errorf("alphabet must not contain %q", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet contains duplicate character %q", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// encodeBase 将无符号整数编码为指定字符集的字符串
+func encodeBase(num uint64, alphabet string) string {
 	if num == 0 {
-		return "0"
+		return string(alphabet[0])
+	}
+
+	base := uint64(len(alphabet))
+	var buf [64]byte
+	i := len(buf)
+	for num > 0 {
+		i--
+		buf[i] = alphabet[num%base]
+		num /= base
 	}
 
-	const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
-	result := ""
-	n := num
+	return string(buf[i:])
+}
 
-	for n > 0 {
-		remainder := n % 62
-		result = string(base62Chars[remainder]) + result
-		n = n / 62
+// decodeBase 将指定字符集的字符串解码为无符号整数
+func decodeBase(code string, alphabet string) (uint64, error) {
+	if code == "" {
+		return 0, ErrCodeUndecodable
 	}
 
-	return result
+	base := uint64(len(alphabet))
+	var num uint64
+	for i := 0; i < len(code); i++ {
+		idx := strings.IndexByte(alphabet, code[i])
+		if idx == -1 {
+			return 0, fmt.Errorf("%w: invalid character %q", ErrCodeUndecodable, code[i])
+		}
+		hi, lo := bits.Mul64(num, base)
+		if hi != 0 {
+			return 0, fmt.Errorf("%w: value overflows", ErrCodeUndecodable)
+		}
+		sum, carry := bits.Add64(lo, uint64(idx), 0)
+		if carry != 0 {
+			return 0, fmt.Errorf("%w: value overflows", ErrCodeUndecodable)
+		}
+		num = sum
+	}
+
+	return num, nil
+}
+
+// padCode 不足指定长度时左侧用字符集首字符补齐
+func padCode(code string, length int, alphabet string) string {
+	if len(code) >= length {
+		return code
+	}
+	return strings.Repeat(string(alphabet[0]), length-len(code)) + code
 }
 
-// GenerateRandomCode 生成指定长度的随机短链码
-func GenerateRandomCode(length int) (string, error) {
-	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+// generateRandomCode 生成指定字符集和长度的随机短链码
+func generateRandomCode(alphabet string, length int) (string, error) {
 	result := make([]byte, length)
+	max := big.NewInt(int64(len(alphabet)))
 
 	for i := range result {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		num, err := rand.Int(rand.Reader, max)
 		if err != nil {
 			return "", err
 		}
-		result[i] = charset[num.Int64()]
+		result[i] = alphabet[num.Int64()]
 	}
 
 	return string(result), nil
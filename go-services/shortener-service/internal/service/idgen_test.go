@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"shortener-service/internal/repo"
+)
+
+const testObfuscationKey = "0123456789abcdef-test-key"
+
+func TestCodeEncoderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		alphabet   string
+		codeLength int
+		key        string
+		ids        []uint64
+	}{
+		{
+			name:       "plain base62",
+			alphabet:   DefaultAlphabet,
+			codeLength: 6,
+			ids:        []uint64{0, 1, 61, 62, 56800235583, 56800235584, math.MaxInt64, math.MaxUint64},
+		},
+		{
+			name:       "obfuscated base62",
+			alphabet:   DefaultAlphabet,
+			codeLength: 6,
+			key:        testObfuscationKey,
+			ids:        []uint64{0, 1, 61, 62, 56800235583, 56800235584, math.MaxInt64, math.MaxUint64},
+		},
+		{
+			name:       "obfuscated decimal tier boundaries",
+			alphabet:   "0123456789",
+			codeLength: 2,
+			key:        testObfuscationKey,
+			ids:        []uint64{0, 9, 10, 99, 100, 999, 1000, 9999, 10000, 1e18, math.MaxUint64},
+		},
+		{
+			name:       "obfuscated binary",
+			alphabet:   "01",
+			codeLength: 3,
+			key:        testObfuscationKey,
+			ids:        []uint64{0, 7, 8, 15, 16, 1 << 32, math.MaxUint64},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder, err := NewCodeEncoder(tt.alphabet, tt.codeLength, tt.key)
+			if err != nil {
+				t.Fatalf("NewCodeEncoder: %v", err)
+			}
+			for _, id := range tt.ids {
+				code := encoder.Encode(id)
+				if len(code) < tt.codeLength {
+					t.Errorf("Encode(%d) = %q, shorter than %d", id, code, tt.codeLength)
+				}
+				got, err := encoder.Decode(code)
+				if err != nil {
+					t.Errorf("Decode(%q): %v", code, err)
+					continue
+				}
+				if got != id {
+					t.Errorf("Decode(Encode(%d)) = %d", id, got)
+				}
+			}
+		})
+	}
+}
+
+func TestObfuscatedEncoderUniqueAcrossTiers(t *testing.T) {
+	tests := []struct {
+		name       string
+		alphabet   string
+		codeLength int
+		count      uint64
+	}{
+		{name: "decimal", alphabet: "0123456789", codeLength: 2, count: 20000},
+		{name: "binary", alphabet: "01", codeLength: 3, count: 5000},
+		{name: "base62", alphabet: DefaultAlphabet, codeLength: 1, count: 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder, err := NewCodeEncoder(tt.alphabet, tt.codeLength, testObfuscationKey)
+			if err != nil {
+				t.Fatalf("NewCodeEncoder: %v", err)
+			}
+			plain, err := NewCodeEncoder(tt.alphabet, tt.codeLength, "")
+			if err != nil {
+				t.Fatalf("NewCodeEncoder: %v", err)
+			}
+
+			seen := make(map[string]uint64, tt.count)
+			for id := uint64(0); id < tt.count; id++ {
+				code := encoder.Encode(id)
+				if prev, ok := seen[code]; ok {
+					t.Fatalf("Encode(%d) = %q, same as Encode(%d)", id, code, prev)
+				}
+				seen[code] = id
+
+				// 置换不改变长度，短链码与直接编码落在同一层
+				if want := len(plain.Encode(id)); len(code) != want {
+					t.Errorf("Encode(%d) = %q, want length %d", id, code, want)
+				}
+				if got, err := encoder.Decode(code); err != nil || got != id {
+					t.Errorf("Decode(%q) = %d, %v, want %d", code, got, err, id)
+				}
+			}
+		})
+	}
+}
+
+func TestObfuscatedEncoderDiffersByKey(t *testing.T) {
+	a, err := NewCodeEncoder(DefaultAlphabet, 6, testObfuscationKey)
+	if err != nil {
+		t.Fatalf("NewCodeEncoder: %v", err)
+	}
+	b, err := NewCodeEncoder(DefaultAlphabet, 6, testObfuscationKey+"-other")
+	if err != nil {
+		t.Fatalf("NewCodeEncoder: %v", err)
+	}
+
+	same := 0
+	for id := uint64(1); id <= 100; id++ {
+		if a.Encode(id) == b.Encode(id) {
+			same++
+		}
+	}
+	if same > 1 {
+		t.Errorf("%d of 100 codes are identical under different keys", same)
+	}
+}
+
+func TestCodeEncoderDecodeInvalid(t *testing.T) {
+	encoder, err := NewCodeEncoder("0123456789", 2, testObfuscationKey)
+	if err != nil {
+		t.Fatalf("NewCodeEncoder: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "empty", code: ""},
+		{name: "invalid character", code: "1a"},
+		{name: "shorter than code length", code: "7"},
+		{name: "overflows uint64", code: "99999999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := encoder.Decode(tt.code); !errors.Is(err, ErrCodeUndecodable) {
+				t.Errorf("Decode(%q) error = %v, want ErrCodeUndecodable", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestNewCodeEncoderShortKey(t *testing.T) {
+	if _, err := NewCodeEncoder(DefaultAlphabet, 6, "short"); err == nil {
+		t.Error("NewCodeEncoder with a short key succeeded, want error")
+	}
+}
+
+func TestSnowflakeIDGenConcurrent(t *testing.T) {
+	encoder, err := NewCodeEncoder(DefaultAlphabet, 6, "")
+	if err != nil {
+		t.Fatalf("NewCodeEncoder: %v", err)
+	}
+	gen, err := NewSnowflakeIDGen(1, encoder, nil)
+	if err != nil {
+		t.Fatalf("NewSnowflakeIDGen: %v", err)
+	}
+
+	const (
+		workers = 16
+		calls   = 50000
+	)
+	ids := make([][]int64, workers)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ids[w] = make([]int64, 0, calls)
+			for i := 0; i < calls; i++ {
+				id, err := gen.GenerateID()
+				if err != nil {
+					errs <- err
+					return
+				}
+				ids[w] = append(ids[w], id)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	// 时钟没有回拨，任何错误都是误判
+	for err := range errs {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	seen := make(map[int64]bool, workers*calls)
+	for _, list := range ids {
+		for _, id := range list {
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+// fakeLeaseRedis 只实现释放租约
+type fakeLeaseRedis struct {
+	repo.RedisRepo
+	released int64
+}
+
+func (r *fakeLeaseRedis) ReleaseMachineLease(ctx context.Context, machineID int64, token string, lastMillis int64) error {
+	r.released = lastMillis
+	return nil
+}
+
+func TestSnowflakeIDGenRejectsIDsBeforeLeaseLastTimestamp(t *testing.T) {
+	encoder, err := NewCodeEncoder(DefaultAlphabet, 6, "")
+	if err != nil {
+		t.Fatalf("NewCodeEncoder: %v", err)
+	}
+	// 上一个持有者的最后时间晚于当前时间，模拟拿到租约后时钟回拨
+	last := time.Now().Add(time.Minute).UnixMilli()
+	redis := &fakeLeaseRedis{}
+	lease := &MachineLease{
+		redisRepo:  redis,
+		machineID:  1,
+		ttl:        time.Hour,
+		lastMillis: last,
+		validUntil: time.Now().Add(time.Hour),
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	gen, err := NewSnowflakeIDGen(lease.MachineID(), encoder, lease)
+	if err != nil {
+		t.Fatalf("NewSnowflakeIDGen: %v", err)
+	}
+
+	if _, err := gen.GenerateID(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Errorf("GenerateID err = %v, want %v", err, ErrClockMovedBackwards)
+	}
+	if err := gen.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if redis.released != last {
+		t.Errorf("released last timestamp = %d, want %d", redis.released, last)
+	}
+}
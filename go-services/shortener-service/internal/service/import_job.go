@@ -0,0 +1,650 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"shortener-service/internal/middleware"
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+	"shortener-service/internal/types"
+)
+
+var (
+	ErrImportJobNotFound = errors.New("import job not found")
+	ErrImportNotFinished = errors.New("import job not finished")
+	ErrImportInvalid     = errors.New("invalid import file")
+	ErrImportQueueFull   = errors.New("too many pending import jobs")
+	ErrImportExpired     = errors.New("import result expired")
+)
+
+// 导入文件的列名，url 必填，其余可选，列顺序不限
+const (
+	importColURL        = "url"
+	importColCustomCode = "custom_code"
+	importColTitle      = "title"
+	importColExpireAt   = "expire_at"
+)
+
+// 等待处理的导入任务队列长度
+const importQueueSize = 100
+
+// 清理过期结果文件的间隔
+const importCleanupInterval = time.Hour
+
+// 结果文件名后缀，文件名为 <任务ID>.result.csv
+const importResultSuffix = ".result.csv"
+
+// importResultHeader 结果CSV表头
+var importResultHeader = []string{"row", "url", "custom_code", "title", "expire_at", "short_code", "short_url", "status", "error"}
+
+// ImportJobService CSV批量导入任务
+type ImportJobService interface {
+	CreateImportJob(ctx context.Context, file io.Reader) (*types.ImportJobResponse, error)
+	GetImportJob(ctx context.Context, id string) (*types.ImportJobResponse, error)
+	OpenImportResult(ctx context.Context, id string) (io.ReadCloser, error)
+}
+
+// importJobService CSV批量导入任务实现
+// 上传的文件和结果文件保存在 dir 下，多实例部署时需要共享该目录；
+// 上传文件在任务结束后删除，结果文件在任务结束 retention 后删除；
+// 任务在本实例的后台协程中按批调用批量创建，进度写入 import_jobs 表；
+// 任务不会跨实例接管，实例退出时进行中和排队中的任务标记为失败，需重新上传；
+// 实例异常退出遗留的未结束任务在启动时按 staleAfter 判定为失败
+type importJobService struct {
+	jobRepo    repo.ImportJobRepo
+	shortener  ShortenerService
+	dir        string
+	maxRows    int
+	staleAfter time.Duration
+	retention  time.Duration
+	errMessage func(error) string // 结果文件中展示的错误信息，不暴露内部错误
+	queue      chan string
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewImportJobService 创建导入任务服务，errMessage 把单行的错误转换为写入结果文件的信息
+func NewImportJobService(jobRepo repo.ImportJobRepo, shortener ShortenerService, dir string, maxRows int, staleAfter, retention time.Duration,
+	errMessage func(error) string) (*importJobService, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create import dir: %w", err)
+	}
+	return &importJobService{
+		jobRepo:    jobRepo,
+		shortener:  shortener,
+		dir:        dir,
+		maxRows:    maxRows,
+		staleAfter: staleAfter,
+		retention:  retention,
+		errMessage: errMessage,
+		queue:      make(chan string, importQueueSize),
+		stopCh:     make(chan struct{}),
+	}, nil
+}
+
+// Start 标记遗留的未结束任务为失败，然后启动 workers 个后台协程处理导入任务，
+// 以及一个定期清理过期文件的协程
+func (s *importJobService) Start(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	// 其他实例正在处理的任务每批都会更新进度，不会被误判
+	if s.staleAfter > 0 {
+		n, err := s.jobRepo.FailStale(context.Background(), time.Now().Add(-s.staleAfter), "service restarted, please upload again")
+		if err != nil {
+			log.Printf("Failed to fail stale import jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("Marked %d stale import jobs as failed", n)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for {
+				select {
+				case id := <-s.queue:
+					s.run(id)
+				case <-s.stopCh:
+					return
+				}
+			}
+		}()
+	}
+
+	if s.retention > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			ticker := time.NewTicker(importCleanupInterval)
+			defer ticker.Stop()
+			for {
+				s.cleanup()
+				select {
+				case <-ticker.C:
+				case <-s.stopCh:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop 停止处理，等待进行中的任务完成当前批次，排队中的任务标记为失败
+func (s *importJobService) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	s.wg.Wait()
+
+	for {
+		select {
+		case id := <-s.queue:
+			s.abandon(id)
+		default:
+			return
+		}
+	}
+}
+
+// CreateImportJob 保存上传的CSV，校验表头和行数后创建任务并排队处理
+func (s *importJobService) CreateImportJob(ctx context.Context, file io.Reader) (*types.ImportJobResponse, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	inputPath := s.inputPath(id)
+	if err := saveFile(inputPath, file); err != nil {
+		return nil, fmt.Errorf("failed to save import file: %w", err)
+	}
+
+	total, err := s.countRows(inputPath)
+	if err != nil {
+		_ = os.Remove(inputPath)
+		return nil, err
+	}
+
+	job := &model.ImportJob{
+		ID:     id,
+		UserID: ownerOf(ctx),
+		Status: model.ImportJobPending,
+		Total:  total,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		_ = os.Remove(inputPath)
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	select {
+	case s.queue <- id:
+	default:
+		s.finish(job, model.ImportJobPending, ErrImportQueueFull)
+		return nil, ErrImportQueueFull
+	}
+
+	return s.buildResponse(job), nil
+}
+
+// GetImportJob 查询导入任务进度
+func (s *importJobService) GetImportJob(ctx context.Context, id string) (*types.ImportJobResponse, error) {
+	job, err := s.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildResponse(job), nil
+}
+
+// OpenImportResult 打开已完成任务的结果CSV
+func (s *importJobService) OpenImportResult(ctx context.Context, id string) (io.ReadCloser, error) {
+	job, err := s.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.ImportJobCompleted {
+		return nil, fmt.Errorf("%w: status is %s", ErrImportNotFinished, job.Status)
+	}
+	if s.expired(job) {
+		return nil, ErrImportExpired
+	}
+
+	f, err := os.Open(s.resultPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrImportExpired
+		}
+		return nil, fmt.Errorf("failed to open import result: %w", err)
+	}
+	return f, nil
+}
+
+// getJob 查询任务并校验归属，越权访问按不存在处理
+func (s *importJobService) getJob(ctx context.Context, id string) (*model.ImportJob, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, err
+	}
+	if userID := middleware.GetUserID(ctx); userID > 0 && (job.UserID == nil || *job.UserID != userID) {
+		return nil, ErrImportJobNotFound
+	}
+	return job, nil
+}
+
+// countRows 校验表头并统计数据行数
+func (s *importJobService) countRows(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := newImportReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to read header: %v", ErrImportInvalid, err)
+	}
+	if _, err := importColumns(header); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrImportInvalid, err)
+		}
+		total++
+		if total > s.maxRows {
+			return 0, fmt.Errorf("%w: more than %d rows", ErrImportInvalid, s.maxRows)
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("%w: no data rows", ErrImportInvalid)
+	}
+	return total, nil
+}
+
+// run 处理一个导入任务
+func (s *importJobService) run(id string) {
+	ctx := context.Background()
+
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Printf("Failed to load import job %s: %v", id, err)
+		return
+	}
+	job.Status = model.ImportJobRunning
+	if err := s.jobRepo.UpdateIfStatus(ctx, job, model.ImportJobPending, "status"); err != nil {
+		// 排队期间已被标记为失败，不再处理
+		log.Printf("Failed to start import job %s: %v", id, err)
+		s.removeInput(id)
+		return
+	}
+
+	// 以上传者的身份创建短链接
+	if job.UserID != nil {
+		ctx = middleware.WithUserID(ctx, *job.UserID)
+	}
+
+	s.finish(job, model.ImportJobRunning, s.process(ctx, job))
+}
+
+// abandon 把未开始处理的任务标记为失败
+func (s *importJobService) abandon(id string) {
+	job, err := s.jobRepo.GetByID(context.Background(), id)
+	if err != nil {
+		log.Printf("Failed to load import job %s: %v", id, err)
+		return
+	}
+	s.finish(job, model.ImportJobPending, errors.New("service stopped, please upload again"))
+}
+
+// process 按批读取上传文件、创建短链接并写入结果文件
+func (s *importJobService) process(ctx context.Context, job *model.ImportJob) error {
+	in, err := os.Open(s.inputPath(job.ID))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(s.resultPath(job.ID))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	reader := newImportReader(in)
+	writer := csv.NewWriter(out)
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	cols, err := importColumns(header)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(importResultHeader); err != nil {
+		return err
+	}
+
+	rowNo := 0
+	for {
+		select {
+		case <-s.stopCh:
+			return errors.New("service stopped")
+		default:
+		}
+
+		// 读取一批，解析失败的行直接记为失败，其余交给批量创建
+		var rows []importRow
+		var reqs []types.ShortenRequest
+		for len(rows) < maxBatchSize {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			rowNo++
+
+			row := importRow{no: rowNo, record: cols.values(record)}
+			req, err := row.request()
+			if err != nil {
+				row.err = err
+			} else {
+				row.reqIndex = len(reqs)
+				reqs = append(reqs, *req)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		var outcomes []BatchOutcome
+		if len(reqs) > 0 {
+			outcomes, err = s.shortener.BatchCreateShortLinks(ctx, &types.BatchShortenRequest{Items: reqs})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, row := range rows {
+			var resp *types.ShortenResponse
+			if row.err == nil {
+				outcome := outcomes[row.reqIndex]
+				resp, row.err = outcome.Response, outcome.Err
+			}
+			if err := writer.Write(row.result(resp, s.errMessage)); err != nil {
+				return err
+			}
+			if row.err == nil {
+				job.Succeeded++
+			} else {
+				job.Failed++
+			}
+			job.Processed++
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		// 任务已被其他实例标记为失败时停止处理
+		err := s.jobRepo.UpdateIfStatus(ctx, job, model.ImportJobRunning, "processed", "succeeded", "failed")
+		if errors.Is(err, repo.ErrImportJobStatusChanged) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Failed to update import job %s progress: %v", job.ID, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// finish 删除上传文件，任务仍处于 from 状态时记录结束状态，已被其他流程结束的任务保持原状态
+func (s *importJobService) finish(job *model.ImportJob, from string, err error) {
+	s.removeInput(job.ID)
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = model.ImportJobCompleted
+	if err != nil {
+		job.Status = model.ImportJobFailed
+		job.Error = err.Error()
+		if len(job.Error) > 500 {
+			job.Error = job.Error[:500]
+		}
+	}
+	if err := s.jobRepo.UpdateIfStatus(context.Background(), job, from,
+		"status", "error", "finished_at", "processed", "succeeded", "failed"); err != nil {
+		log.Printf("Failed to update import job %s: %v", job.ID, err)
+	}
+}
+
+// removeInput 删除上传文件
+func (s *importJobService) removeInput(id string) {
+	if err := os.Remove(s.inputPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove import file %s: %v", id, err)
+	}
+}
+
+// cleanup 删除已结束且超过保留时间的任务的结果文件
+// 上传文件由处理任务的协程在任务结束时删除，这里不处理，避免删除排队中或进行中任务的上传文件
+func (s *importJobService) cleanup() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("Failed to read import dir: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), importResultSuffix)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		job, err := s.jobRepo.GetByID(ctx, id)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("Failed to load import job %s: %v", id, err)
+			}
+			continue
+		}
+		if !s.expired(job) {
+			continue
+		}
+		if err := os.Remove(s.resultPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove expired import result %s: %v", id, err)
+		}
+	}
+}
+
+// inputPath 上传文件路径
+func (s *importJobService) inputPath(id string) string {
+	return filepath.Join(s.dir, id+".csv")
+}
+
+// resultPath 结果文件路径
+func (s *importJobService) resultPath(id string) string {
+	return filepath.Join(s.dir, id+importResultSuffix)
+}
+
+// buildResponse 构建任务进度响应
+func (s *importJobService) buildResponse(job *model.ImportJob) *types.ImportJobResponse {
+	resp := &types.ImportJobResponse{
+		JobID:      job.ID,
+		Status:     job.Status,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Succeeded:  job.Succeeded,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	if job.Status == model.ImportJobCompleted && !s.expired(job) {
+		resp.ResultURL = fmt.Sprintf("/api/import/jobs/%s/result", job.ID)
+	}
+	return resp
+}
+
+// importColumnIndex 导入文件各列的位置，-1 表示没有该列
+type importColumnIndex struct {
+	url, customCode, title, expireAt int
+}
+
+// importColumns 按表头定位各列
+func importColumns(header []string) (*importColumnIndex, error) {
+	cols := &importColumnIndex{url: -1, customCode: -1, title: -1, expireAt: -1}
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case importColURL:
+			cols.url = i
+		case importColCustomCode:
+			cols.customCode = i
+		case importColTitle:
+			cols.title = i
+		case importColExpireAt:
+			cols.expireAt = i
+		}
+	}
+	if cols.url == -1 {
+		return nil, fmt.Errorf("%w: missing %q column", ErrImportInvalid, importColURL)
+	}
+	return cols, nil
+}
+
+// values 按 url, custom_code, title, expire_at 的顺序取出一行的值
+func (c *importColumnIndex) values(record []string) [4]string {
+	var v [4]string
+	for i, idx := range []int{c.url, c.customCode, c.title, c.expireAt} {
+		if idx >= 0 && idx < len(record) {
+			v[i] = strings.TrimSpace(record[idx])
+		}
+	}
+	return v
+}
+
+// importRow 导入文件中的一行
+type importRow struct {
+	no       int
+	record   [4]string // url, custom_code, title, expire_at
+	reqIndex int       // 在本批创建请求中的位置
+	err      error
+}
+
+// request 转换为创建请求
+func (r *importRow) request() (*types.ShortenRequest, error) {
+	req := &types.ShortenRequest{
+		OriginalURL: r.record[0],
+		CustomCode:  r.record[1],
+		Title:       r.record[2],
+	}
+	if r.record[3] != "" {
+		expireAt, err := parseImportTime(r.record[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid expire_at %q", ErrImportInvalid, r.record[3])
+		}
+		req.ExpireAt = &expireAt
+	}
+	return req, nil
+}
+
+// result 生成结果文件中的一行，errMessage 把错误转换为展示给用户的信息
+func (r *importRow) result(resp *types.ShortenResponse, errMessage func(error) string) []string {
+	row := []string{strconv.Itoa(r.no), r.record[0], r.record[1], r.record[2], r.record[3], "", "", "success", ""}
+	if r.err != nil {
+		row[7] = "failed"
+		row[8] = errMessage(r.err)
+		return EscapeCSVRecord(row)
+	}
+	row[5] = resp.ShortCode
+	row[6] = resp.ShortURL
+	return EscapeCSVRecord(row)
+}
+
+// parseImportTime 解析过期时间，支持 RFC3339、"2006-01-02 15:04:05" 和 "2006-01-02"（本地时区）
+func parseImportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// EscapeCSVRecord 在以公式字符开头的单元格前加单引号，防止表格软件把内容当作公式执行
+func EscapeCSVRecord(record []string) []string {
+	for i, v := range record {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			record[i] = "'" + v
+		}
+	}
+	return record
+}
+
+// newImportReader 创建CSV读取器，允许各行列数不同
+func newImportReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// saveFile 将上传内容写入文件
+func saveFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// newJobID 生成不可猜测的任务ID
+func newJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// expired 任务的结果文件是否已超过保留时间
+func (s *importJobService) expired(job *model.ImportJob) bool {
+	return s.retention > 0 && job.FinishedAt != nil && time.Since(*job.FinishedAt) > s.retention
+}
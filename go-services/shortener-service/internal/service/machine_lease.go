@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+
+	"shortener-service/internal/repo"
+)
+
+var (
+	ErrMachineLeaseLost    = errors.New("snowflake machine id lease lost")
+	ErrNoMachineID         = errors.New("no snowflake machine id available")
+	ErrClockMovedBackwards = errors.New("clock moved backwards")
+)
+
+// MachineLease 雪花算法机器ID租约
+// 启动时从Redis抢占一个空闲的机器ID，后台定期续约，停止时释放；
+// 续约失败超过有效期或租约被他人持有时视为丢失，此后拒绝生成ID
+type MachineLease struct {
+	redisRepo repo.RedisRepo
+	machineID int64
+	token     string
+	ttl       time.Duration
+	// 上一个持有者最后生成的ID时间(毫秒)
+	lastMillis int64
+
+	mu         sync.Mutex
+	validUntil time.Time // 本地认为租约仍有效的截止时间
+	lost       bool
+	started    bool
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// AcquireMachineLease 从随机位置开始依次尝试抢占机器ID租约
+// 机器ID上次生成的ID时间晚于当前时间时（时钟回拨）跳过该机器ID
+func AcquireMachineLease(ctx context.Context, redisRepo repo.RedisRepo, ttl time.Duration) (*MachineLease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid machine lease ttl: %s", ttl)
+	}
+
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+
+	total := int64(1) << snowflake.NodeBits
+	offset, err := rand.Int(rand.Reader, big.NewInt(total))
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := 0
+	for i := int64(0); i < total; i++ {
+		machineID := (offset.Int64() + i) % total
+
+		start := time.Now()
+		ok, err := redisRepo.AcquireMachineLease(ctx, machineID, token, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire machine lease: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		// 抢到后再读取最后使用时间，上一个持有者释放前已写入
+		lastMillis, err := redisRepo.GetMachineLastTimestamp(ctx, machineID)
+		if err != nil {
+			_ = redisRepo.ReleaseMachineLease(ctx, machineID, token, 0)
+			return nil, fmt.Errorf("failed to read machine last timestamp: %w", err)
+		}
+		if lastMillis > time.Now().UnixMilli() {
+			_ = redisRepo.ReleaseMachineLease(ctx, machineID, token, 0)
+			skipped++
+			continue
+		}
+
+		return &MachineLease{
+			redisRepo:  redisRepo,
+			machineID:  machineID,
+			token:      token,
+			ttl:        ttl,
+			lastMillis: lastMillis,
+			validUntil: start.Add(ttl - ttl/10),
+			stopCh:     make(chan struct{}),
+			done:       make(chan struct{}),
+		}, nil
+	}
+
+	if skipped > 0 {
+		return nil, fmt.Errorf("%w: %d machine ids skipped due to %v", ErrNoMachineID, skipped, ErrClockMovedBackwards)
+	}
+	return nil, ErrNoMachineID
+}
+
+// MachineID 租约对应的机器ID
+func (l *MachineLease) MachineID() int64 {
+	return l.machineID
+}
+
+// LastTimestamp 上一个持有者最后生成的ID时间(毫秒)，新持有者生成的ID不能早于该时间
+func (l *MachineLease) LastTimestamp() int64 {
+	return l.lastMillis
+}
+
+// Valid 检查租约是否仍然有效
+func (l *MachineLease) Valid() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lost || time.Now().After(l.validUntil) {
+		return ErrMachineLeaseLost
+	}
+	return nil
+}
+
+// Start 启动后台续约，lastMillis 返回最近一次生成的ID时间，随续约写入Redis
+func (l *MachineLease) Start(lastMillis func() int64) {
+	l.mu.Lock()
+	l.started = true
+	l.mu.Unlock()
+
+	go func() {
+		defer close(l.done)
+
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if !l.renew(lastMillis()) {
+					return
+				}
+			case <-l.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Release 停止续约并释放租约
+func (l *MachineLease) Release(lastMillis int64) error {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+	})
+
+	l.mu.Lock()
+	started := l.started
+	l.lost = true
+	l.mu.Unlock()
+
+	if started {
+		<-l.done
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return l.redisRepo.ReleaseMachineLease(ctx, l.machineID, l.token, lastMillis)
+}
+
+// renew 续约一次，租约已被他人持有时返回false
+func (l *MachineLease) renew(lastMillis int64) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+
+	start := time.Now()
+	ok, err := l.redisRepo.RenewMachineLease(ctx, l.machineID, l.token, l.ttl, lastMillis)
+	if err != nil {
+		// 暂时性错误继续重试，超过有效期后 Valid 会拒绝生成ID
+		log.Printf("Failed to renew snowflake machine lease %d: %v", l.machineID, err)
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !ok {
+		l.lost = true
+		log.Printf("Snowflake machine lease %d lost, id generation disabled", l.machineID)
+		return false
+	}
+	l.validUntil = start.Add(l.ttl - l.ttl/10)
+	return true
+}
+
+// newLeaseToken 生成租约持有者标识
+func newLeaseToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+)
+
+// ErrURLBlocked 目标地址命中黑名单
+var ErrURLBlocked = errors.New("destination is blocked")
+
+// 回溯禁用时每批扫描的行数
+const screenScanBatch = 500
+
+// Blocklist 目标地址黑名单（加载后只读）
+type Blocklist struct {
+	hosts     map[string]struct{}         // 精确主机名
+	wildcards map[string]struct{}         // 域名及其所有子域名
+	prefixes  map[int]map[string]struct{} // Safe-Browsing 风格的 SHA-256 哈希前缀，按前缀长度分组
+}
+
+// newBlocklist 创建空黑名单
+func newBlocklist() *Blocklist {
+	return &Blocklist{
+		hosts:     make(map[string]struct{}),
+		wildcards: make(map[string]struct{}),
+		prefixes:  make(map[int]map[string]struct{}),
+	}
+}
+
+// Empty 是否没有任何条目
+func (b *Blocklist) Empty() bool {
+	return len(b.hosts) == 0 && len(b.wildcards) == 0 && len(b.prefixes) == 0
+}
+
+// Diff 返回 b 中有而 old 中没有的条目
+func (b *Blocklist) Diff(old *Blocklist) *Blocklist {
+	added := newBlocklist()
+	for host := range b.hosts {
+		if _, ok := old.hosts[host]; !ok {
+			added.hosts[host] = struct{}{}
+		}
+	}
+	for domain := range b.wildcards {
+		if _, ok := old.wildcards[domain]; !ok {
+			added.wildcards[domain] = struct{}{}
+		}
+	}
+	for n, set := range b.prefixes {
+		for prefix := range set {
+			if _, ok := old.prefixes[n][prefix]; !ok {
+				added.addPrefix(prefix)
+			}
+		}
+	}
+	return added
+}
+
+// Match 检查URL是否命中黑名单，命中时返回原因
+func (b *Blocklist) Match(u *url.URL) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+
+	if _, ok := b.hosts[host]; ok {
+		return "host " + host, true
+	}
+
+	for domain := host; domain != ""; {
+		if _, ok := b.wildcards[domain]; ok {
+			return "domain *." + domain, true
+		}
+		idx := strings.IndexByte(domain, '.')
+		if idx == -1 {
+			break
+		}
+		domain = domain[idx+1:]
+	}
+
+	if len(b.prefixes) > 0 {
+		for _, expr := range urlExpressions(u) {
+			sum := sha256.Sum256([]byte(expr))
+			for n, set := range b.prefixes {
+				if _, ok := set[string(sum[:n])]; ok {
+					return "hash prefix of " + expr, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// addPrefix 添加哈希前缀
+func (b *Blocklist) addPrefix(prefix string) {
+	set, ok := b.prefixes[len(prefix)]
+	if !ok {
+		set = make(map[string]struct{})
+		b.prefixes[len(prefix)] = set
+	}
+	set[prefix] = struct{}{}
+}
+
+// loadHosts 加载主机名黑名单文件
+// 每行一个主机名，"*.example.com" 表示 example.com 及其所有子域名，"#" 开头为注释
+func (b *Blocklist) loadHosts(path string) error {
+	return readListFile(path, func(line string) error {
+		wildcard := strings.HasPrefix(line, "*.")
+		host, err := normalizeHost(strings.TrimPrefix(line, "*."))
+		if err != nil {
+			return err
+		}
+		if wildcard {
+			b.wildcards[host] = struct{}{}
+		} else {
+			b.hosts[host] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// loadHashPrefixes 加载哈希前缀文件
+// 每行一个十六进制编码的 SHA-256 前缀（4~32字节），"#" 开头为注释
+func (b *Blocklist) loadHashPrefixes(path string) error {
+	return readListFile(path, func(line string) error {
+		prefix, err := hex.DecodeString(line)
+		if err != nil {
+			return fmt.Errorf("invalid hash prefix %q: %w", line, err)
+		}
+		if len(prefix) < 4 || len(prefix) > sha256.Size {
+			return fmt.Errorf("invalid hash prefix length %q", line)
+		}
+		b.addPrefix(string(prefix))
+		return nil
+	})
+}
+
+// readListFile 逐行读取名单文件，跳过空行和注释
+func readListFile(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// urlExpressions 按 Safe Browsing 规则生成 主机后缀/路径前缀 组合表达式
+func urlExpressions(u *url.URL) []string {
+	host := strings.ToLower(u.Hostname())
+
+	// 主机：完整主机名 + 最后5段起依次去掉开头一段（不含顶级域），最多5个
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		parts := strings.Split(host, ".")
+		start := len(parts) - 5
+		if start < 1 {
+			start = 1
+		}
+		for i := start; i < len(parts)-1; i++ {
+			hosts = append(hosts, strings.Join(parts[i:], "."))
+		}
+	}
+
+	// 路径：带查询参数的完整路径、完整路径 + 从根开始依次追加路径段，最多6个
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	paths := make([]string, 0, 6)
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path)
+	prefix := "/"
+	if path != prefix {
+		paths = append(paths, prefix)
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(segments)-1 && i < 3; i++ {
+		prefix += segments[i] + "/"
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+	}
+
+	exprs := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			exprs = append(exprs, h+p)
+		}
+	}
+	return exprs
+}
+
+// Screener 目标地址安全检查
+// 定期检查名单文件修改时间并热加载，启动时和新增条目时回溯禁用已存在的匹配短链
+type Screener struct {
+	hostsFile string
+	hashFile  string
+	interval  time.Duration
+	retroScan bool
+	dbRepo    repo.ShortLinkRepo
+	redisRepo repo.RedisRepo
+	mu        sync.RWMutex
+	list      *Blocklist
+	modTimes  map[string]time.Time
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewScreener 创建目标地址安全检查器并加载名单
+func NewScreener(
+	hostsFile, hashFile string,
+	reloadInterval int,
+	retroScan bool,
+	dbRepo repo.ShortLinkRepo,
+	redisRepo repo.RedisRepo,
+) (*Screener, error) {
+	s := &Screener{
+		hostsFile: hostsFile,
+		hashFile:  hashFile,
+		interval:  time.Duration(reloadInterval) * time.Second,
+		retroScan: retroScan,
+		dbRepo:    dbRepo,
+		redisRepo: redisRepo,
+		list:      newBlocklist(),
+		modTimes:  make(map[string]time.Time),
+		stopCh:    make(chan struct{}),
+	}
+
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Check 检查目标地址是否允许缩短
+func (s *Screener) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLInvalid, err)
+	}
+
+	s.mu.RLock()
+	list := s.list
+	s.mu.RUnlock()
+
+	if reason, ok := list.Match(u); ok {
+		return fmt.Errorf("%w: %s", ErrURLBlocked, reason)
+	}
+	return nil
+}
+
+// Start 启动名单热加载
+// 开启回溯时先按完整名单检查一遍已有短链，覆盖服务停止期间新增的条目
+func (s *Screener) Start() {
+	if !s.retroScan && s.interval <= 0 {
+		return
+	}
+
+	go func() {
+		if s.retroScan {
+			s.mu.RLock()
+			list := s.list
+			s.mu.RUnlock()
+			if !list.Empty() {
+				if err := s.disableMatching(context.Background(), list); err != nil {
+					log.Printf("Failed to disable blocked links: %v", err)
+				}
+			}
+		}
+		if s.interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				added, err := s.reload()
+				if err != nil {
+					log.Printf("Failed to reload blocklist: %v", err)
+					continue
+				}
+				if s.retroScan && added != nil && !added.Empty() {
+					if err := s.disableMatching(context.Background(), added); err != nil {
+						log.Printf("Failed to disable blocked links: %v", err)
+					}
+				}
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止名单热加载
+func (s *Screener) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+// reload 名单文件有变化时重新加载，返回新增的条目；文件未变化时返回nil
+func (s *Screener) reload() (*Blocklist, error) {
+	changed := false
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{s.hostsFile, s.hashFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat blocklist: %w", err)
+		}
+		modTimes[path] = info.ModTime()
+		if !info.ModTime().Equal(s.modTimes[path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	list := newBlocklist()
+	if s.hostsFile != "" {
+		if err := list.loadHosts(s.hostsFile); err != nil {
+			return nil, fmt.Errorf("failed to load host blocklist: %w", err)
+		}
+	}
+	if s.hashFile != "" {
+		if err := list.loadHashPrefixes(s.hashFile); err != nil {
+			return nil, fmt.Errorf("failed to load hash prefix blocklist: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	old := s.list
+	s.list = list
+	s.modTimes = modTimes
+	s.mu.Unlock()
+
+	prefixCount := 0
+	for _, set := range list.prefixes {
+		prefixCount += len(set)
+	}
+	log.Printf("Blocklist loaded: %d hosts, %d domains, %d hash prefixes",
+		len(list.hosts), len(list.wildcards), prefixCount)
+
+	return list.Diff(old), nil
+}
+
+// disableMatching 禁用命中新增条目的已有短链，并清除缓存
+func (s *Screener) disableMatching(ctx context.Context, added *Blocklist) error {
+	var afterID uint64
+	disabled := 0
+
+	for {
+		links, err := s.dbRepo.ListActiveAfter(ctx, afterID, screenScanBatch)
+		if err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			break
+		}
+		afterID = links[len(links)-1].ID
+
+		var matched []*model.ShortLink
+		for _, link := range links {
+			u, err := url.Parse(link.OriginalURL)
+			if err != nil {
+				continue
+			}
+			if _, ok := added.Match(u); ok {
+				matched = append(matched, link)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		codes := make([]string, 0, len(matched))
+		for _, link := range matched {
+			codes = append(codes, link.ShortCode)
+		}
+		if err := s.dbRepo.DisableByCodes(ctx, codes); err != nil {
+			return err
+		}
+		for _, link := range matched {
+			_ = s.redisRepo.DeleteShortLink(ctx, link)
+		}
+		disabled += len(matched)
+	}
+
+	if disabled > 0 {
+		log.Printf("Disabled %d short links matching new blocklist entries", disabled)
+	}
+	return nil
+}
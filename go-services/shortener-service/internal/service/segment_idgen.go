@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"shortener-service/internal/repo"
+)
+
+// 当前号段剩余比例低于该值时异步预取下一个号段
+const segmentPrefetchRatio = 0.2
+
+// idSegment 内存中的号段
+type idSegment struct {
+	next uint64 // 下一个可用ID
+	max  uint64 // 号段内最大ID（含）
+}
+
+// remaining 剩余可用ID数
+func (s *idSegment) remaining() uint64 {
+	if s == nil || s.next > s.max {
+		return 0
+	}
+	return s.max - s.next + 1
+}
+
+// SegmentIDGen 号段模式ID生成器（Leaf-segment）
+// 从MySQL批量租用连续ID区间，双缓冲：当前号段消耗到一定比例时后台预取下一个号段，
+// 多个实例各自租用互不重叠的号段，无需为每个实例分配机器ID
+type SegmentIDGen struct {
+	segmentRepo repo.SegmentRepo
+	encoder     CodeEncoder
+	bizTag      string
+	step        int
+
+	mu       sync.Mutex
+	current  *idSegment
+	buffered *idSegment    // 预取的下一个号段
+	loading  bool          // 是否有预取在进行
+	loadDone chan struct{} // 预取完成时关闭
+	loadErr  error         // 最近一次预取的错误
+}
+
+// NewSegmentIDGen 创建号段模式ID生成器，并同步加载第一个号段
+func NewSegmentIDGen(segmentRepo repo.SegmentRepo, encoder CodeEncoder, bizTag string, step int) (*SegmentIDGen, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("invalid segment step: %d", step)
+	}
+
+	g := &SegmentIDGen{
+		segmentRepo: segmentRepo,
+		encoder:     encoder,
+		bizTag:      bizTag,
+		step:        step,
+	}
+
+	seg, err := g.fetch()
+	if err != nil {
+		return nil, err
+	}
+	g.current = seg
+
+	return g, nil
+}
+
+// GenerateID 生成唯一ID
+func (g *SegmentIDGen) GenerateID() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for {
+		if g.current.remaining() > 0 {
+			id := g.current.next
+			g.current.next++
+
+			if g.buffered == nil && !g.loading &&
+				float64(g.current.remaining()) < float64(g.step)*segmentPrefetchRatio {
+				g.startLoad()
+			}
+			return int64(id), nil
+		}
+
+		// 当前号段用完，切换到预取的号段
+		if g.buffered != nil {
+			g.current, g.buffered = g.buffered, nil
+			continue
+		}
+
+		// 没有可用的预取号段，等待加载完成
+		if !g.loading {
+			g.startLoad()
+		}
+		done := g.loadDone
+		g.mu.Unlock()
+		<-done
+		g.mu.Lock()
+
+		if g.buffered == nil && g.loadErr != nil {
+			return 0, fmt.Errorf("failed to allocate id segment: %w", g.loadErr)
+		}
+	}
+}
+
+// GenerateShortCode 生成短链码
+func (g *SegmentIDGen) GenerateShortCode() (string, error) {
+	id, err := g.GenerateID()
+	if err != nil {
+		return "", err
+	}
+	return g.encoder.Encode(uint64(id)), nil
+}
+
+// startLoad 后台加载下一个号段，调用方需持有锁
+func (g *SegmentIDGen) startLoad() {
+	g.loading = true
+	g.loadDone = make(chan struct{})
+
+	go func(done chan struct{}) {
+		seg, err := g.fetch()
+
+		g.mu.Lock()
+		if err == nil {
+			g.buffered = seg
+		}
+		g.loadErr = err
+		g.loading = false
+		g.mu.Unlock()
+
+		close(done)
+	}(g.loadDone)
+}
+
+// fetch 从数据库租用一个号段
+func (g *SegmentIDGen) fetch() (*idSegment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start, end, err := g.segmentRepo.NextSegment(ctx, g.bizTag, g.step)
+	if err != nil {
+		return nil, err
+	}
+	return &idSegment{next: start, max: end}, nil
+}
@@ -2,12 +2,19 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
 	"time"
 
+	"github.com/zeromicro/go-zero/core/syncx"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"shortener-service/internal/middleware"
 	"shortener-service/internal/model"
 	"shortener-service/internal/repo"
 	"shortener-service/internal/types"
@@ -17,186 +24,804 @@ var (
 	ErrShortCodeExists   = errors.New("short code already exists")
 	ErrShortCodeNotFound = errors.New("short code not found")
 	ErrURLInvalid        = errors.New("invalid url")
+	ErrStatusInvalid     = errors.New("invalid status")
+	ErrCursorInvalid     = errors.New("invalid cursor")
+	ErrSortInvalid       = errors.New("invalid sort field")
+	ErrMaxVisitsInvalid  = errors.New("invalid max visits")
+	ErrScheduleInvalid   = errors.New("start_at must be before expire_at")
+	ErrPasswordInvalid   = errors.New("invalid password")
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// 导出时每批查询的行数
+	exportBatchSize = 500
+
+	// 访问密码长度范围(字节)，bcrypt 只使用前72字节
+	minPasswordLength = 4
+	maxPasswordLength = 72
+
+	// 自动生成短链码冲突时的最大尝试次数
+	maxGenerateAttempts = 5
+
+	// 缓存剩余时间低于 cacheTTL 的该比例时开始按概率提前刷新，越接近过期概率越高
+	earlyRefreshRatio = 0.1
 )
 
 // ShortenerService 短链服务接口
 type ShortenerService interface {
 	CreateShortLink(ctx context.Context, req *types.ShortenRequest) (*types.ShortenResponse, error)
-	BatchCreateShortLinks(ctx context.Context, urls []string) (*types.BatchShortenResponse, error)
+	BatchCreateShortLinks(ctx context.Context, req *types.BatchShortenRequest) ([]BatchOutcome, error)
 	GetShortLink(ctx context.Context, code string) (*types.GetLinkResponse, error)
-	GetOriginalURL(ctx context.Context, code string) (string, error)
+	UpdateShortLink(ctx context.Context, code string, req *types.UpdateLinkRequest) (*types.GetLinkResponse, error)
+	DeleteShortLink(ctx context.Context, code string) error
+	ListShortLinks(ctx context.Context, req *types.ListLinksRequest) (*types.ListLinksResponse, error)
+	ExportShortLinks(ctx context.Context, req *types.ListLinksRequest, fn func(*types.GetLinkResponse) error) error
 }
 
 // shortenerService 短链服务实现
 type shortenerService struct {
 	dbRepo    repo.ShortLinkRepo
 	redisRepo repo.RedisRepo
+	campaigns repo.CampaignRepo
 	idGen     IDGenerator
+	urlNorm   *URLNormalizer
+	screener  *Screener // 为空表示不做黑名单检查
+	aliases   *AliasPolicy
+	codes     *CodeFilter // 为空表示不使用布隆过滤器
 	domain    string
 	cacheTTL  time.Duration
+	// 负缓存过期时间
+	negativeTTL time.Duration
+	// 合并同一短链码的并发回源
+	loads syncx.SingleFlight
 }
 
 // NewShortenerService 创建短链服务实例
 func NewShortenerService(
 	dbRepo repo.ShortLinkRepo,
 	redisRepo repo.RedisRepo,
+	campaigns repo.CampaignRepo,
 	idGen IDGenerator,
+	urlNorm *URLNormalizer,
+	screener *Screener,
+	aliases *AliasPolicy,
+	codes *CodeFilter,
 	domain string,
 	cacheTTL int,
+	negativeTTL int,
 ) ShortenerService {
 	return &shortenerService{
-		dbRepo:    dbRepo,
-		redisRepo: redisRepo,
-		idGen:     idGen,
-		domain:    domain,
-		cacheTTL:  time.Duration(cacheTTL) * time.Second,
+		dbRepo:      dbRepo,
+		redisRepo:   redisRepo,
+		campaigns:   campaigns,
+		idGen:       idGen,
+		urlNorm:     urlNorm,
+		screener:    screener,
+		aliases:     aliases,
+		codes:       codes,
+		domain:      domain,
+		cacheTTL:    time.Duration(cacheTTL) * time.Second,
+		negativeTTL: time.Duration(negativeTTL) * time.Second,
+		loads:       syncx.NewSingleFlight(),
 	}
 }
 
 // CreateShortLink 创建短链接
 func (s *shortenerService) CreateShortLink(ctx context.Context, req *types.ShortenRequest) (*types.ShortenResponse, error) {
-	// 检查是否已存在相同URL的短链
-	if code, err := s.redisRepo.GetShortCodeByURL(ctx, req.OriginalURL); err == nil && code != "" {
-		link, err := s.dbRepo.GetByShortCode(ctx, code)
-		if err == nil && link != nil {
-			return s.buildResponse(link), nil
+	link, reused, err := s.prepareLink(ctx, req, ownerOf(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return s.buildResponse(link), nil
+	}
+
+	if err := s.createLink(ctx, s.dbRepo, link, req.CustomCode != ""); err != nil {
+		return nil, err
+	}
+
+	// 缓存到Redis
+	_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+
+	return s.buildResponse(link), nil
+}
+
+// prepareLink 校验创建请求并构建待写入的短链接
+// 开启去重且找到可复用的短链接时返回该短链接和 reused=true；使用自定义短链码时已填入 ShortCode
+func (s *shortenerService) prepareLink(ctx context.Context, req *types.ShortenRequest, owner *uint64) (*model.ShortLink, bool, error) {
+	var campaign *model.Campaign
+	if req.CampaignID != nil {
+		var err error
+		if campaign, err = ownerCampaign(ctx, s.campaigns, *req.CampaignID, owner); err != nil {
+			return nil, false, err
 		}
 	}
 
-	// 查询数据库
-	if link, err := s.dbRepo.GetByOriginalURL(ctx, req.OriginalURL); err == nil {
-		// 更新缓存
-		_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
-		return s.buildResponse(link), nil
+	// 合并请求中的UTM参数和活动的UTM模板
+	explicit, err := parseUTM(req.UTM)
+	if err != nil {
+		return nil, false, err
+	}
+	var defaults model.UTM
+	if campaign != nil {
+		defaults = campaign.UTM
+	}
+	rawURL, err := applyUTM(req.OriginalURL, explicit, defaults)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// 生成短链码
-	var shortCode string
-	var err error
+	// 校验并规范化URL，后续去重和入库都使用规范化后的URL
+	originalURL, err := s.urlNorm.Normalize(rawURL, req.StripFragment)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.screen(originalURL); err != nil {
+		return nil, false, err
+	}
 
-	if req.CustomCode != "" {
-		// 使用自定义短链码
-		shortCode = req.CustomCode
-		// 检查是否已存在
-		if exists, _ := s.redisRepo.Exists(ctx, shortCode); exists {
-			return nil, ErrShortCodeExists
-		}
-		if _, err := s.dbRepo.GetByShortCode(ctx, shortCode); err == nil {
-			return nil, ErrShortCodeExists
-		}
-	} else {
-		// 自动生成短链码
-		shortCode, err = s.idGen.GenerateShortCode()
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate short code: %w", err)
+	// 按创建者去重（仅在请求显式开启且未指定自定义短链码、未限制访问次数、未设置密码、未归入活动或标签时）
+	if req.Dedupe && req.CustomCode == "" && req.MaxVisits == nil && !req.OneTime && req.Password == "" &&
+		req.CampaignID == nil && len(req.Tags) == 0 {
+		if link := s.findReusableLink(ctx, owner, originalURL); link != nil {
+			return link, true, nil
 		}
 	}
 
+	maxVisits, err := requestMaxVisits(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var passwordHash string
+	if req.Password != "" {
+		if passwordHash, err = hashPassword(req.Password); err != nil {
+			return nil, false, err
+		}
+	}
+
+	tagIDs, err := resolveTags(ctx, s.campaigns, owner, req.Tags)
+	if err != nil {
+		return nil, false, err
+	}
+
 	// 创建短链接记录
 	link := &model.ShortLink{
-		ShortCode:   shortCode,
-		OriginalURL: req.OriginalURL,
-		Title:       req.Title,
-		Description: req.Description,
-		ExpireAt:    req.ExpireAt,
-		Status:      1,
+		OriginalURL:  originalURL,
+		UTM:          extractUTM(originalURL),
+		CampaignID:   req.CampaignID,
+		TagIDs:       tagIDs,
+		Title:        req.Title,
+		Description:  req.Description,
+		StartAt:      req.StartAt,
+		ExpireAt:     req.ExpireAt,
+		MaxVisits:    maxVisits,
+		PasswordHash: passwordHash,
+		Status:       1,
+		UserID:       owner,
 	}
 
-	// 保存到数据库
-	if err := s.dbRepo.Create(ctx, link); err != nil {
-		return nil, fmt.Errorf("failed to create short link: %w", err)
+	if err := checkSchedule(link); err != nil {
+		return nil, false, err
 	}
 
-	// 缓存到Redis
-	_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+	if req.CustomCode != "" {
+		// 使用自定义短链码，先按策略校验
+		link.ShortCode, err = s.aliases.Normalize(req.CustomCode)
+		if err != nil {
+			return nil, false, err
+		}
+		// 布隆过滤器判定可能存在且缓存中已存在时快速失败，最终以唯一索引为准
+		if s.mightExist(ctx, link.ShortCode) {
+			if exists, _ := s.redisRepo.Exists(ctx, link.ShortCode); exists {
+				return nil, false, ErrShortCodeExists
+			}
+		}
+	}
 
-	return s.buildResponse(link), nil
+	return link, false, nil
 }
 
-// BatchCreateShortLinks 批量创建短链接
-func (s *shortenerService) BatchCreateShortLinks(ctx context.Context, urls []string) (*types.BatchShortenResponse, error) {
-	response := &types.BatchShortenResponse{
-		Results: make([]types.ShortenResponse, 0, len(urls)),
+// createLink 写入短链接，自动生成的短链码冲突时重新生成
+func (s *shortenerService) createLink(ctx context.Context, r repo.ShortLinkRepo, link *model.ShortLink, custom bool) error {
+	if custom {
+		return s.insertLink(ctx, r, link)
 	}
 
-	for _, url := range urls {
-		req := &types.ShortenRequest{
-			OriginalURL: url,
+	for attempt := 1; ; attempt++ {
+		code, err := s.idGen.GenerateShortCode()
+		if err != nil {
+			return fmt.Errorf("failed to generate short code: %w", err)
 		}
+		link.ShortCode = code
 
-		result, err := s.CreateShortLink(ctx, req)
-		if err != nil {
-			response.Failed++
-			continue
+		err = s.insertLink(ctx, r, link)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrShortCodeExists) || attempt >= maxGenerateAttempts {
+			return err
+		}
+	}
+}
+
+// insertLink 写入短链接，短链码唯一索引冲突时返回 ErrShortCodeExists
+// 软删除的墓碑行同样占用唯一索引，已删除的短链码不能被重新占用
+func (s *shortenerService) insertLink(ctx context.Context, r repo.ShortLinkRepo, link *model.ShortLink) error {
+	if err := r.Create(ctx, link); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrShortCodeExists
+		}
+		return fmt.Errorf("failed to create short link: %w", err)
+	}
+	if s.codes != nil {
+		s.codes.Add(ctx, link.ShortCode)
+	}
+	return nil
+}
+
+// findReusableLink 查找创建者已有的相同URL且仍可用的短链接
+func (s *shortenerService) findReusableLink(ctx context.Context, owner *uint64, originalURL string) *model.ShortLink {
+	if code, err := s.redisRepo.GetShortCodeByURL(ctx, owner, originalURL); err == nil && code != "" {
+		link, err := s.dbRepo.GetByShortCode(ctx, code)
+		if err == nil && sameOwner(link.UserID, owner) && link.OriginalURL == originalURL && link.IsActive() {
+			return link
 		}
+	}
 
-		response.Results = append(response.Results, *result)
-		response.Success++
+	// 查询数据库
+	link, err := s.dbRepo.GetByOwnerAndURL(ctx, owner, originalURL)
+	if err != nil || !link.IsActive() {
+		return nil
 	}
 
-	return response, nil
+	// 更新缓存
+	_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+	return link
 }
 
 // GetShortLink 获取短链接详情
 func (s *shortenerService) GetShortLink(ctx context.Context, code string) (*types.GetLinkResponse, error) {
-	// 先查缓存
-	link, err := s.redisRepo.GetShortLink(ctx, code)
-	if err == nil && link != nil {
-		return s.buildDetailResponse(link), nil
+	link, err := s.lookupLink(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess(ctx, link) {
+		return nil, ErrShortCodeNotFound
 	}
 
-	// 查数据库
-	link, err = s.dbRepo.GetByShortCode(ctx, code)
+	// 缓存中的访问次数可能已过时，重新读取数据库中的计数
+	if stats, err := s.dbRepo.GetVisitStats(ctx, code); err == nil {
+		link.VisitCount = stats.VisitCount
+		link.LastVisitedAt = stats.LastVisitedAt
+	}
+	s.addPendingVisits(ctx, []*model.ShortLink{link})
+
+	return s.buildDetailResponses(ctx, []*model.ShortLink{link})[0], nil
+}
+
+// UpdateShortLink 更新短链接
+func (s *shortenerService) UpdateShortLink(ctx context.Context, code string, req *types.UpdateLinkRequest) (*types.GetLinkResponse, error) {
+	if !s.mightExist(ctx, code) {
+		return nil, ErrShortCodeNotFound
+	}
+
+	// 以数据库为准，不读缓存
+	link, err := s.dbRepo.GetByShortCode(ctx, code)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrShortCodeNotFound
 		}
 		return nil, err
 	}
+	if !canAccess(ctx, link) {
+		return nil, ErrShortCodeNotFound
+	}
 
-	// 更新缓存
-	_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+	oldLink := *link
+	// 只写回本次请求修改的列
+	var columns []string
 
-	return s.buildDetailResponse(link), nil
+	// 移入新的活动时与创建时一致，用活动的UTM模板补全目标地址中缺少的UTM参数
+	var campaign *model.Campaign
+	if !req.ClearCampaign && req.CampaignID != nil {
+		if campaign, err = ownerCampaign(ctx, s.campaigns, *req.CampaignID, link.UserID); err != nil {
+			return nil, err
+		}
+	}
+	var defaults model.UTM
+	if campaign != nil && (link.CampaignID == nil || *link.CampaignID != campaign.ID) {
+		defaults = campaign.UTM
+	}
+
+	if req.OriginalURL != nil || req.UTM != nil || !defaults.IsZero() {
+		rawURL := link.OriginalURL
+		if req.OriginalURL != nil {
+			rawURL = *req.OriginalURL
+		}
+		explicit, err := parseUTM(req.UTM)
+		if err != nil {
+			return nil, err
+		}
+		if rawURL, err = applyUTM(rawURL, explicit, defaults); err != nil {
+			return nil, err
+		}
+
+		originalURL, err := s.urlNorm.Normalize(rawURL, req.StripFragment)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.screen(originalURL); err != nil {
+			return nil, err
+		}
+		link.OriginalURL = originalURL
+		link.UTM = extractUTM(originalURL)
+		columns = append(columns, "original_url", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content")
+	}
+	if req.Title != nil {
+		link.Title = *req.Title
+		columns = append(columns, "title")
+	}
+	if req.Description != nil {
+		link.Description = *req.Description
+		columns = append(columns, "description")
+	}
+	if req.Status != nil {
+		if *req.Status != 0 && *req.Status != 1 {
+			return nil, ErrStatusInvalid
+		}
+		link.Status = *req.Status
+		columns = append(columns, "status")
+	}
+	if req.ClearStart {
+		link.StartAt = nil
+		columns = append(columns, "start_at")
+	} else if req.StartAt != nil {
+		link.StartAt = req.StartAt
+		columns = append(columns, "start_at")
+	}
+	if req.ClearExpire {
+		link.ExpireAt = nil
+		columns = append(columns, "expire_at")
+	} else if req.ExpireAt != nil {
+		link.ExpireAt = req.ExpireAt
+		columns = append(columns, "expire_at")
+	}
+	if err := checkSchedule(link); err != nil {
+		return nil, err
+	}
+	if req.ClearPassword {
+		link.PasswordHash = ""
+		columns = append(columns, "password_hash")
+	} else if req.Password != nil {
+		if link.PasswordHash, err = hashPassword(*req.Password); err != nil {
+			return nil, err
+		}
+		columns = append(columns, "password_hash")
+	}
+	if req.ClearMaxVisits {
+		link.MaxVisits = nil
+		columns = append(columns, "max_visits")
+	} else if req.MaxVisits != nil {
+		if *req.MaxVisits == 0 {
+			return nil, ErrMaxVisitsInvalid
+		}
+		link.MaxVisits = req.MaxVisits
+		columns = append(columns, "max_visits")
+	}
+	if req.ClearCampaign {
+		link.CampaignID = nil
+		columns = append(columns, "campaign_id")
+	} else if campaign != nil {
+		link.CampaignID = &campaign.ID
+		columns = append(columns, "campaign_id")
+	}
+	if req.Tags != nil {
+		if link.TagIDs, err = resolveTags(ctx, s.campaigns, link.UserID, *req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.dbRepo.Transaction(ctx, func(txRepo repo.ShortLinkRepo) error {
+		if err := txRepo.Update(ctx, link, columns); err != nil {
+			return err
+		}
+		if req.Tags != nil {
+			return txRepo.SetTags(ctx, link.ID, link.TagIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		// 读取之后被并发删除
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrShortCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to update short link: %w", err)
+	}
+
+	// 上限变化后按当前访问次数重置已消耗的额度；只修改过期时间时同步额度计数的过期时间
+	if req.ClearMaxVisits || req.MaxVisits != nil {
+		if err := s.resetVisitQuota(ctx, link); err != nil {
+			return nil, fmt.Errorf("failed to reset visit quota: %w", err)
+		}
+	} else if link.MaxVisits != nil && (req.ClearExpire || req.ExpireAt != nil) {
+		if err := s.redisRepo.ExpireVisitQuota(ctx, link.ShortCode, link.ExpireAt); err != nil {
+			return nil, fmt.Errorf("failed to update visit quota: %w", err)
+		}
+	}
+
+	// 写库后删除缓存，redirect-service 直接读取 short:code: 前缀，不能留下旧数据
+	if err := s.redisRepo.DeleteShortLink(ctx, &oldLink); err != nil {
+		return nil, fmt.Errorf("failed to invalidate cache: %w", err)
+	}
+	if link.OriginalURL != oldLink.OriginalURL {
+		if err := s.redisRepo.DeleteShortLink(ctx, link); err != nil {
+			return nil, fmt.Errorf("failed to invalidate cache: %w", err)
+		}
+	}
+
+	return s.buildDetailResponses(ctx, []*model.ShortLink{link})[0], nil
 }
 
-// GetOriginalURL 获取原始URL（用于重定向）
-func (s *shortenerService) GetOriginalURL(ctx context.Context, code string) (string, error) {
-	// 先查缓存
-	link, err := s.redisRepo.GetShortLink(ctx, code)
+// DeleteShortLink 删除短链接（软删除）
+func (s *shortenerService) DeleteShortLink(ctx context.Context, code string) error {
+	if !s.mightExist(ctx, code) {
+		return ErrShortCodeNotFound
+	}
+
+	link, err := s.dbRepo.GetByShortCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrShortCodeNotFound
+		}
+		return err
+	}
+	if !canAccess(ctx, link) {
+		return ErrShortCodeNotFound
+	}
+
+	if err := s.dbRepo.Delete(ctx, code); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrShortCodeNotFound
+		}
+		return fmt.Errorf("failed to delete short link: %w", err)
+	}
+
+	// 清除两类缓存后写入负缓存，重定向服务立即停止解析该短链码
+	if err := s.redisRepo.DeleteShortLink(ctx, link); err != nil {
+		return fmt.Errorf("failed to invalidate cache: %w", err)
+	}
+	if err := s.redisRepo.SetNotFound(ctx, code, s.negativeTTL); err != nil {
+		return fmt.Errorf("failed to set negative cache: %w", err)
+	}
+	if link.MaxVisits != nil {
+		if err := s.redisRepo.ClearVisitQuota(ctx, code); err != nil {
+			return fmt.Errorf("failed to clear visit quota: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListShortLinks 游标分页查询短链接列表
+func (s *shortenerService) ListShortLinks(ctx context.Context, req *types.ListLinksRequest) (*types.ListLinksResponse, error) {
+	opts, err := listOptions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	if opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, ErrCursorInvalid
+		}
+		opts.Cursor = cursor
+	}
+
+	// 多取一条判断是否还有下一页
+	pageSize := opts.Limit
+	opts.Limit++
+	links, err := s.dbRepo.List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list short links: %w", err)
+	}
+
+	resp := &types.ListLinksResponse{
+		Links: make([]types.GetLinkResponse, 0, pageSize),
+	}
+	if len(links) > pageSize {
+		links = links[:pageSize]
+		resp.HasMore = true
+	}
+	// 游标使用数据库中的值，需在累加未落库的访问计数之前生成
+	if resp.HasMore {
+		resp.NextCursor = encodeCursor(cursorOf(links[len(links)-1]))
+	}
+
+	s.addPendingVisits(ctx, links)
+	for _, detail := range s.buildDetailResponses(ctx, links) {
+		resp.Links = append(resp.Links, *detail)
+	}
+
+	return resp, nil
+}
+
+// ExportShortLinks 按列表查询条件逐条导出全部短链接，忽略分页参数
+// 按批次游标查询，内存占用与总数无关；fn 返回错误时停止导出
+func (s *shortenerService) ExportShortLinks(ctx context.Context, req *types.ListLinksRequest, fn func(*types.GetLinkResponse) error) error {
+	opts, err := listOptions(ctx, req)
+	if err != nil {
+		return err
+	}
+	opts.Limit = exportBatchSize
+
+	for {
+		links, err := s.dbRepo.List(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list short links: %w", err)
+		}
+
+		if len(links) == 0 {
+			return nil
+		}
+		next := cursorOf(links[len(links)-1])
+
+		s.addPendingVisits(ctx, links)
+		for _, detail := range s.buildDetailResponses(ctx, links) {
+			if err := fn(detail); err != nil {
+				return err
+			}
+		}
+
+		if len(links) < opts.Limit {
+			return nil
+		}
+		opts.Cursor = next
+	}
+}
+
+// listOptions 校验列表查询条件并转换为查询选项，按调用方身份过滤
+func listOptions(ctx context.Context, req *types.ListLinksRequest) (*repo.ListOptions, error) {
+	opts := &repo.ListOptions{
+		Status:      req.Status,
+		Expired:     req.Expired,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		Keyword:     strings.TrimSpace(req.Query),
+		CampaignID:  req.CampaignID,
+		Tags:        req.Tags,
+		SortBy:      repo.SortByCreatedAt,
+		Limit:       req.Limit,
+		UserID:      ownerOf(ctx),
+	}
+
+	switch req.SortBy {
+	case "", repo.SortByCreatedAt:
+	case repo.SortByVisitCount:
+		opts.SortBy = repo.SortByVisitCount
+	default:
+		return nil, ErrSortInvalid
+	}
+
+	switch strings.ToLower(req.Order) {
+	case "", "desc":
+	case "asc":
+		opts.Asc = true
+	default:
+		return nil, ErrSortInvalid
+	}
+
+	return opts, nil
+}
+
+// cursorOf 以短链接为分页位置生成游标
+func cursorOf(link *model.ShortLink) *repo.ListCursor {
+	return &repo.ListCursor{
+		CreatedAt:  link.CreatedAt,
+		VisitCount: link.VisitCount,
+		ID:         link.ID,
+	}
+}
+
+// encodeCursor 将游标编码为不透明字符串
+func encodeCursor(c *repo.ListCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解码游标
+func decodeCursor(s string) (*repo.ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c repo.ListCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// lookupLink 读取短链接：先查缓存，未命中时经布隆过滤器后合并回源
+// 热点key临近过期时按概率在后台提前刷新，避免过期瞬间大量请求同时回源
+func (s *shortenerService) lookupLink(ctx context.Context, code string) (*model.ShortLink, error) {
+	link, ttl, err := s.redisRepo.GetShortLink(ctx, code)
 	if err == nil && link != nil {
-		if !link.IsActive() {
-			return "", errors.New("short link is inactive or expired")
+		if s.shouldRefreshEarly(ttl) {
+			go func() {
+				_, _ = s.loadLink(context.Background(), code)
+			}()
 		}
-		// 异步增加访问计数
-		go func() {
-			_ = s.dbRepo.IncrementVisitCount(context.Background(), code)
-		}()
-		return link.OriginalURL, nil
+		return link, nil
+	}
+	if errors.Is(err, repo.ErrNotFoundCached) || !s.mightExist(ctx, code) {
+		return nil, ErrShortCodeNotFound
 	}
 
-	// 查数据库
-	link, err = s.dbRepo.GetByShortCode(ctx, code)
+	return s.loadLink(ctx, code)
+}
+
+// loadLink 从数据库加载短链接并写入缓存，同一短链码的并发加载只查询一次数据库
+// 不存在时写入负缓存
+func (s *shortenerService) loadLink(ctx context.Context, code string) (*model.ShortLink, error) {
+	val, err := s.loads.Do(code, func() (interface{}, error) {
+		// 结果由所有等待者共享，不受发起者取消的影响
+		ctx := context.WithoutCancel(ctx)
+
+		link, err := s.dbRepo.GetByShortCode(ctx, code)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = s.redisRepo.SetNotFound(ctx, code, s.negativeTTL)
+				return nil, ErrShortCodeNotFound
+			}
+			return nil, err
+		}
+
+		_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+		return link, nil
+	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", ErrShortCodeNotFound
+		return nil, err
+	}
+
+	// 返回副本，避免调用方修改共享结果
+	link := *val.(*model.ShortLink)
+	return &link, nil
+}
+
+// shouldRefreshEarly 缓存剩余时间进入提前刷新窗口后，按线性递增的概率决定是否提前刷新
+func (s *shortenerService) shouldRefreshEarly(ttl time.Duration) bool {
+	window := time.Duration(float64(s.cacheTTL) * earlyRefreshRatio)
+	if ttl <= 0 || window <= 0 || ttl >= window {
+		return false
+	}
+	return rand.Float64() >= float64(ttl)/float64(window)
+}
+
+// screen 黑名单检查
+func (s *shortenerService) screen(originalURL string) error {
+	if s.screener == nil {
+		return nil
+	}
+	return s.screener.Check(originalURL)
+}
+
+// mightExist 布隆过滤器判定短链码是否可能存在，未启用时始终为true
+func (s *shortenerService) mightExist(ctx context.Context, code string) bool {
+	if s.codes == nil {
+		return true
+	}
+	return s.codes.MightExist(ctx, code)
+}
+
+// addPendingVisits 把 redirect-service 写入Redis、尚未合并到数据库的访问增量累加到短链接上
+func (s *shortenerService) addPendingVisits(ctx context.Context, links []*model.ShortLink) {
+	if len(links) == 0 {
+		return
+	}
+
+	codes := make([]string, len(links))
+	for i, link := range links {
+		codes[i] = link.ShortCode
+	}
+	pending, _ := s.redisRepo.GetPendingVisits(ctx, codes)
+
+	for _, link := range links {
+		delta := pending[link.ShortCode]
+
+		link.VisitCount += delta.Count
+		if !delta.LastVisitedAt.IsZero() &&
+			(link.LastVisitedAt == nil || delta.LastVisitedAt.After(*link.LastVisitedAt)) {
+			last := delta.LastVisitedAt
+			link.LastVisitedAt = &last
 		}
-		return "", err
 	}
+}
 
-	if !link.IsActive() {
-		return "", errors.New("short link is inactive or expired")
+// resetVisitQuota 修改访问次数上限后，以包含未落库计数的访问次数作为已消耗的额度
+func (s *shortenerService) resetVisitQuota(ctx context.Context, link *model.ShortLink) error {
+	if link.MaxVisits == nil {
+		return s.redisRepo.ClearVisitQuota(ctx, link.ShortCode)
 	}
+	current := *link
+	s.addPendingVisits(ctx, []*model.ShortLink{&current})
+	return s.redisRepo.SetVisitQuota(ctx, link.ShortCode, current.VisitCount, link.ExpireAt)
+}
 
-	// 更新缓存
-	_ = s.redisRepo.SetShortLink(ctx, link, s.cacheTTL)
+// checkSchedule 生效时间和过期时间同时设置时，生效时间必须早于过期时间
+func checkSchedule(link *model.ShortLink) error {
+	if link.StartAt != nil && link.ExpireAt != nil && !link.StartAt.Before(*link.ExpireAt) {
+		return ErrScheduleInvalid
+	}
+	return nil
+}
+
+// hashPassword 校验访问密码长度并生成bcrypt哈希
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: length must be between %d and %d bytes", ErrPasswordInvalid, minPasswordLength, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// requestMaxVisits 解析创建请求中的访问次数上限，one_time 等同于上限为1
+func requestMaxVisits(req *types.ShortenRequest) (*uint64, error) {
+	if req.OneTime {
+		if req.MaxVisits != nil && *req.MaxVisits != 1 {
+			return nil, fmt.Errorf("%w: one_time conflicts with max_visits", ErrMaxVisitsInvalid)
+		}
+		one := uint64(1)
+		return &one, nil
+	}
+	if req.MaxVisits != nil && *req.MaxVisits == 0 {
+		return nil, ErrMaxVisitsInvalid
+	}
+	return req.MaxVisits, nil
+}
 
-	// 异步增加访问计数
-	go func() {
-		_ = s.dbRepo.IncrementVisitCount(context.Background(), code)
-	}()
+// canAccess 检查调用方是否有权访问短链接
+// 无身份的请求视为内部调用（如 redirect-service 回源），不做限制；
+// 有身份时只能访问自己创建的短链接，越权访问统一按不存在处理，避免泄露短链码是否存在
+func canAccess(ctx context.Context, link *model.ShortLink) bool {
+	return ownedBy(ctx, link.UserID)
+}
+
+// ownedBy 检查调用方是否为 owner，规则同 canAccess
+func ownedBy(ctx context.Context, owner *uint64) bool {
+	userID := middleware.GetUserID(ctx)
+	if userID == 0 {
+		return true
+	}
+	return owner != nil && *owner == userID
+}
 
-	return link.OriginalURL, nil
+// ownerOf 调用方身份对应的创建者，无身份时为空
+func ownerOf(ctx context.Context) *uint64 {
+	if userID := middleware.GetUserID(ctx); userID > 0 {
+		return &userID
+	}
+	return nil
+}
+
+// sameOwner 比较两个创建者是否相同
+func sameOwner(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
 
 // buildResponse 构建响应
@@ -212,13 +837,37 @@ func (s *shortenerService) buildResponse(link *model.ShortLink) *types.ShortenRe
 // buildDetailResponse 构建详情响应
 func (s *shortenerService) buildDetailResponse(link *model.ShortLink) *types.GetLinkResponse {
 	return &types.GetLinkResponse{
-		ShortCode:   link.ShortCode,
-		OriginalURL: link.OriginalURL,
-		Title:       link.Title,
-		Description: link.Description,
-		VisitCount:  link.VisitCount,
-		Status:      link.Status,
-		ExpireAt:    link.ExpireAt,
-		CreatedAt:   link.CreatedAt,
+		ShortCode:         link.ShortCode,
+		ShortURL:          fmt.Sprintf("%s/%s", s.domain, link.ShortCode),
+		OriginalURL:       link.OriginalURL,
+		Title:             link.Title,
+		Description:       link.Description,
+		VisitCount:        link.VisitCount,
+		LastVisitedAt:     link.LastVisitedAt,
+		MaxVisits:         link.MaxVisits,
+		PasswordProtected: link.PasswordHash != "",
+		CampaignID:        link.CampaignID,
+		UserID:            link.UserID,
+		UTM:               utmParams(link.UTM),
+		Status:            link.Status,
+		StartAt:           link.StartAt,
+		ExpireAt:          link.ExpireAt,
+		CreatedAt:         link.CreatedAt,
+	}
+}
+
+// buildDetailResponses 批量构建详情响应并填入标签名，标签查询失败时不返回标签
+func (s *shortenerService) buildDetailResponses(ctx context.Context, links []*model.ShortLink) []*types.GetLinkResponse {
+	ids := make([]uint64, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+	tags, _ := s.dbRepo.GetTagNames(ctx, ids)
+
+	resp := make([]*types.GetLinkResponse, len(links))
+	for i, link := range links {
+		resp[i] = s.buildDetailResponse(link)
+		resp[i].Tags = tags[link.ID]
 	}
+	return resp
 }
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+	"shortener-service/internal/types"
+)
+
+// fakeUpdateRepo 单行短链表，读取后执行 afterRead 模拟并发修改；只实现更新用到的方法
+type fakeUpdateRepo struct {
+	repo.ShortLinkRepo
+	row       model.ShortLink
+	deleted   bool
+	afterRead func(row *fakeUpdateRepo)
+}
+
+func (r *fakeUpdateRepo) GetByShortCode(ctx context.Context, code string) (*model.ShortLink, error) {
+	if r.deleted || code != r.row.ShortCode {
+		return nil, gorm.ErrRecordNotFound
+	}
+	link := r.row
+	if r.afterRead != nil {
+		r.afterRead(r)
+	}
+	return &link, nil
+}
+
+// Update 与数据库一致：已删除的行不更新，只写入指定的列
+func (r *fakeUpdateRepo) Update(ctx context.Context, link *model.ShortLink, columns []string) error {
+	if r.deleted || link.ID != r.row.ID {
+		return gorm.ErrRecordNotFound
+	}
+	for _, column := range columns {
+		switch column {
+		case "original_url":
+			r.row.OriginalURL = link.OriginalURL
+		case "utm_source":
+			r.row.UTM = link.UTM
+		case "campaign_id":
+			r.row.CampaignID = link.CampaignID
+		case "title":
+			r.row.Title = link.Title
+		case "description":
+			r.row.Description = link.Description
+		case "status":
+			r.row.Status = link.Status
+		case "visit_count":
+			r.row.VisitCount = link.VisitCount
+		}
+	}
+	return nil
+}
+
+func (r *fakeUpdateRepo) Transaction(ctx context.Context, fn func(txRepo repo.ShortLinkRepo) error) error {
+	return fn(r)
+}
+
+func (r *fakeUpdateRepo) GetTagNames(ctx context.Context, linkIDs []uint64) (map[uint64][]string, error) {
+	return nil, nil
+}
+
+func (r *fakeCacheRepo) DeleteShortLink(ctx context.Context, link *model.ShortLink) error {
+	delete(r.cached, link.ShortCode)
+	return nil
+}
+
+// fakeCampaignRepo 只实现查询活动
+type fakeCampaignRepo struct {
+	repo.CampaignRepo
+	campaigns map[uint64]*model.Campaign
+}
+
+func (r *fakeCampaignRepo) GetCampaign(ctx context.Context, id uint64) (*model.Campaign, error) {
+	if c, ok := r.campaigns[id]; ok {
+		return c, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func newUpdateTestService(links *fakeUpdateRepo, campaigns ...*model.Campaign) *shortenerService {
+	cache := &fakeCacheRepo{cached: make(map[string]bool)}
+	campaignRepo := &fakeCampaignRepo{campaigns: make(map[uint64]*model.Campaign)}
+	for _, c := range campaigns {
+		campaignRepo.campaigns[c.ID] = c
+	}
+	svc := NewShortenerService(links, cache, campaignRepo, &seqIDGen{}, NewURLNormalizer([]string{"http", "https"}, 2048),
+		nil, nil, nil, "http://s.test", 3600, 60)
+	return svc.(*shortenerService)
+}
+
+func TestUpdateShortLinkKeepsConcurrentChanges(t *testing.T) {
+	links := &fakeUpdateRepo{
+		row: model.ShortLink{ID: 1, ShortCode: "abc", OriginalURL: "https://a.example/", Status: 1, VisitCount: 5},
+		afterRead: func(r *fakeUpdateRepo) {
+			// 读取之后对账写入访问次数，额度用尽被禁用
+			r.row.VisitCount = 42
+			r.row.Status = 0
+		},
+	}
+	svc := newUpdateTestService(links)
+
+	title := "renamed"
+	if _, err := svc.UpdateShortLink(context.Background(), "abc", &types.UpdateLinkRequest{Title: &title}); err != nil {
+		t.Fatalf("UpdateShortLink: %v", err)
+	}
+	if links.row.Title != title {
+		t.Errorf("title = %q, want %q", links.row.Title, title)
+	}
+	if links.row.VisitCount != 42 || links.row.Status != 0 {
+		t.Errorf("visit_count, status = %d, %d, want 42, 0", links.row.VisitCount, links.row.Status)
+	}
+}
+
+func TestUpdateShortLinkDeletedAfterRead(t *testing.T) {
+	links := &fakeUpdateRepo{
+		row: model.ShortLink{ID: 1, ShortCode: "abc", OriginalURL: "https://a.example/", Status: 1},
+		afterRead: func(r *fakeUpdateRepo) {
+			r.deleted = true
+		},
+	}
+	svc := newUpdateTestService(links)
+
+	title := "renamed"
+	_, err := svc.UpdateShortLink(context.Background(), "abc", &types.UpdateLinkRequest{Title: &title})
+	if !errors.Is(err, ErrShortCodeNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrShortCodeNotFound)
+	}
+	if !links.deleted || links.row.Title != "" {
+		t.Errorf("deleted link was written back: deleted = %v, title = %q", links.deleted, links.row.Title)
+	}
+}
+
+func TestUpdateShortLinkAppliesCampaignUTM(t *testing.T) {
+	campaign := &model.Campaign{ID: 7, Name: "spring", UTM: model.UTM{Source: "newsletter", Medium: "email"}}
+	links := &fakeUpdateRepo{
+		row: model.ShortLink{ID: 1, ShortCode: "abc", OriginalURL: "https://a.example/?utm_source=ads", Status: 1},
+	}
+	svc := newUpdateTestService(links, campaign)
+
+	id := campaign.ID
+	if _, err := svc.UpdateShortLink(context.Background(), "abc", &types.UpdateLinkRequest{CampaignID: &id}); err != nil {
+		t.Fatalf("UpdateShortLink: %v", err)
+	}
+	if links.row.CampaignID == nil || *links.row.CampaignID != id {
+		t.Fatalf("campaign_id = %v, want %d", links.row.CampaignID, id)
+	}
+	// 目标地址已有的UTM参数优先，缺少的由活动模板补全
+	want := model.UTM{Source: "ads", Medium: "email"}
+	if links.row.UTM != want {
+		t.Errorf("utm = %+v, want %+v (url %s)", links.row.UTM, want, links.row.OriginalURL)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrURLTooLong       = errors.New("url too long")
+	ErrSchemeNotAllowed = errors.New("url scheme not allowed")
+)
+
+// defaultPorts 各协议的默认端口，规范化时去掉
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// idnaProfile 国际化域名转换配置，允许下划线等非严格主机名字符
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.BidiRule(),
+)
+
+// URLNormalizer URL校验与规范化
+type URLNormalizer struct {
+	allowedSchemes map[string]bool
+	maxLength      int
+}
+
+// NewURLNormalizer 创建URL规范化器
+func NewURLNormalizer(allowedSchemes []string, maxLength int) *URLNormalizer {
+	schemes := make(map[string]bool, len(allowedSchemes))
+	for _, scheme := range allowedSchemes {
+		schemes[strings.ToLower(scheme)] = true
+	}
+	return &URLNormalizer{
+		allowedSchemes: schemes,
+		maxLength:      maxLength,
+	}
+}
+
+// Normalize 校验并规范化URL
+// 小写协议和主机名、国际化域名转punycode、去掉默认端口、按参数名排序查询参数，
+// stripFragment 为true时去掉锚点
+func (n *URLNormalizer) Normalize(raw string, stripFragment bool) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: url is empty", ErrURLInvalid)
+	}
+	if len(raw) > n.maxLength {
+		return "", fmt.Errorf("%w: exceeds %d bytes", ErrURLTooLong, n.maxLength)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrURLInvalid, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: scheme is required", ErrURLInvalid)
+	}
+	if !n.allowedSchemes[u.Scheme] {
+		return "", fmt.Errorf("%w: %s", ErrSchemeNotAllowed, u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("%w: absolute url with host is required", ErrURLInvalid)
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		// IPv6 字面量需要方括号
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+	u.Host = host
+
+	u.RawQuery = sortQuery(u.RawQuery)
+
+	if stripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	normalized := u.String()
+	if len(normalized) > n.maxLength {
+		return "", fmt.Errorf("%w: exceeds %d bytes after normalization", ErrURLTooLong, n.maxLength)
+	}
+
+	return normalized, nil
+}
+
+// normalizeHost 规范化主机名
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: host is empty", ErrURLInvalid)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idnaProfile.ToASCII(strings.ToLower(host))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid host %q", ErrURLInvalid, host)
+	}
+	return ascii, nil
+}
+
+// sortQuery 按参数名稳定排序查询参数，保留原始编码和同名参数的相对顺序
+func sortQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	pairs := make([]string, 0, strings.Count(rawQuery, "&")+1)
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair != "" {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return queryKey(pairs[i]) < queryKey(pairs[j])
+	})
+	return strings.Join(pairs, "&")
+}
+
+// queryKey 提取查询参数名
+func queryKey(pair string) string {
+	if idx := strings.IndexByte(pair, '='); idx != -1 {
+		return pair[:idx]
+	}
+	return pair
+}
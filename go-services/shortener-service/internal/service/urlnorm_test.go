@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestURLNormalizerNormalize(t *testing.T) {
+	n := NewURLNormalizer([]string{"http", "HTTPS"}, 64)
+
+	tests := []struct {
+		name          string
+		raw           string
+		stripFragment bool
+		want          string
+	}{
+		{
+			name: "lowercases scheme and host",
+			raw:  "HTTPS://Example.COM/Path",
+			want: "https://example.com/Path",
+		},
+		{
+			name: "trims surrounding spaces",
+			raw:  "  https://example.com/  ",
+			want: "https://example.com/",
+		},
+		{
+			name: "drops default http port",
+			raw:  "http://example.com:80/a",
+			want: "http://example.com/a",
+		},
+		{
+			name: "drops default https port",
+			raw:  "https://example.com:443/a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "keeps non-default port",
+			raw:  "https://example.com:8443/a",
+			want: "https://example.com:8443/a",
+		},
+		{
+			name: "converts idn to punycode",
+			raw:  "https://例子.测试/",
+			want: "https://xn--fsqu00a.xn--0zwm56d/",
+		},
+		{
+			name: "keeps ipv6 brackets",
+			raw:  "http://[::1]:8080/",
+			want: "http://[::1]:8080/",
+		},
+		{
+			name: "sorts query by key and keeps duplicate order",
+			raw:  "https://example.com/?b=2&a=1&b=1&&c",
+			want: "https://example.com/?a=1&b=2&b=1&c",
+		},
+		{
+			name: "keeps query encoding",
+			raw:  "https://example.com/?q=a%20b&p=x+y",
+			want: "https://example.com/?p=x+y&q=a%20b",
+		},
+		{
+			name: "keeps fragment",
+			raw:  "https://example.com/#top",
+			want: "https://example.com/#top",
+		},
+		{
+			name:          "strips fragment",
+			raw:           "https://example.com/?a=1#top",
+			stripFragment: true,
+			want:          "https://example.com/?a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := n.Normalize(tt.raw, tt.stripFragment)
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLNormalizerNormalizeErrors(t *testing.T) {
+	n := NewURLNormalizer([]string{"http", "https"}, 32)
+
+	tests := []struct {
+		name string
+		raw  string
+		want error
+	}{
+		{name: "empty", raw: "   ", want: ErrURLInvalid},
+		{name: "too long", raw: "https://example.com/0123456789abcdef", want: ErrURLTooLong},
+		{name: "missing scheme", raw: "example.com/a", want: ErrURLInvalid},
+		{name: "scheme not allowed", raw: "ftp://example.com/", want: ErrSchemeNotAllowed},
+		{name: "javascript scheme", raw: "javascript:alert(1)", want: ErrSchemeNotAllowed},
+		{name: "opaque url", raw: "http:example.com", want: ErrURLInvalid},
+		{name: "missing host", raw: "https:///path", want: ErrURLInvalid},
+		{name: "unparseable", raw: "https://exa mple.com/%zz", want: ErrURLInvalid},
+		{name: "invalid host", raw: "https://-a..b/", want: ErrURLInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := n.Normalize(tt.raw, false); !errors.Is(err, tt.want) {
+				t.Errorf("Normalize(%q) error = %v, want %v", tt.raw, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLNormalizerTooLongAfterNormalization(t *testing.T) {
+	// punycode 转换后变长
+	n := NewURLNormalizer([]string{"https"}, 25)
+	if _, err := n.Normalize("https://例子.测试/", false); !errors.Is(err, ErrURLTooLong) {
+		t.Errorf("Normalize error = %v, want ErrURLTooLong", err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/types"
+)
+
+var ErrUTMInvalid = errors.New("invalid utm parameter")
+
+// 单个UTM参数值的最大长度(字符)，与数据库列长度一致
+const maxUTMLength = 100
+
+// utmField UTM参数名与取值字段的对应关系
+type utmField struct {
+	param string
+	get   func(*model.UTM) *string
+}
+
+// utmFields 全部UTM参数，按标准顺序排列
+var utmFields = []utmField{
+	{"utm_source", func(u *model.UTM) *string { return &u.Source }},
+	{"utm_medium", func(u *model.UTM) *string { return &u.Medium }},
+	{"utm_campaign", func(u *model.UTM) *string { return &u.Campaign }},
+	{"utm_term", func(u *model.UTM) *string { return &u.Term }},
+	{"utm_content", func(u *model.UTM) *string { return &u.Content }},
+}
+
+// parseUTM 把请求中的UTM参数转换为模型并校验，去掉首尾空白
+func parseUTM(p *types.UTMParams) (model.UTM, error) {
+	if p == nil {
+		return model.UTM{}, nil
+	}
+
+	utm := model.UTM{
+		Source:   p.Source,
+		Medium:   p.Medium,
+		Campaign: p.Campaign,
+		Term:     p.Term,
+		Content:  p.Content,
+	}
+	for _, f := range utmFields {
+		v := f.get(&utm)
+		*v = strings.TrimSpace(*v)
+		if utf8.RuneCountInString(*v) > maxUTMLength {
+			return model.UTM{}, fmt.Errorf("%w: %s must be at most %d characters", ErrUTMInvalid, f.param, maxUTMLength)
+		}
+	}
+	return utm, nil
+}
+
+// applyUTM 把UTM参数合并到URL的查询参数中
+// explicit 中的参数覆盖URL中的同名参数，defaults 中的参数只在URL中没有同名参数时添加；
+// 其他查询参数保留原始编码和顺序，锚点不变
+func applyUTM(rawURL string, explicit, defaults model.UTM) (string, error) {
+	if explicit.IsZero() && defaults.IsZero() {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrURLInvalid, err)
+	}
+
+	var pairs []string
+	present := make(map[string]bool)
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, err := url.QueryUnescape(queryKey(pair))
+		if err != nil {
+			key = queryKey(pair)
+		}
+		if f, ok := lookupUTMField(key); ok && *f.get(&explicit) != "" {
+			continue
+		}
+		present[key] = true
+		pairs = append(pairs, pair)
+	}
+
+	for _, f := range utmFields {
+		v := *f.get(&explicit)
+		if v == "" {
+			v = *f.get(&defaults)
+			if v == "" || present[f.param] {
+				continue
+			}
+		}
+		pairs = append(pairs, f.param+"="+url.QueryEscape(v))
+	}
+
+	u.RawQuery = strings.Join(pairs, "&")
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// extractUTM 从URL的查询参数中取出UTM参数，同名参数取第一个，超长部分截断
+func extractUTM(rawURL string) model.UTM {
+	var utm model.UTM
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return utm
+	}
+
+	query := u.Query()
+	for _, f := range utmFields {
+		v := strings.TrimSpace(query.Get(f.param))
+		if utf8.RuneCountInString(v) > maxUTMLength {
+			v = string([]rune(v)[:maxUTMLength])
+		}
+		*f.get(&utm) = v
+	}
+	return utm
+}
+
+// lookupUTMField 按参数名查找UTM参数
+func lookupUTMField(param string) (utmField, bool) {
+	for _, f := range utmFields {
+		if f.param == param {
+			return f, true
+		}
+	}
+	return utmField{}, false
+}
+
+// utmParams UTM参数转换为响应，未设置时为空
+func utmParams(utm model.UTM) *types.UTMParams {
+	if utm.IsZero() {
+		return nil
+	}
+	return &types.UTMParams{
+		Source:   utm.Source,
+		Medium:   utm.Medium,
+		Campaign: utm.Campaign,
+		Term:     utm.Term,
+		Content:  utm.Content,
+	}
+}
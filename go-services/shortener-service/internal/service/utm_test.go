@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/types"
+)
+
+func TestApplyUTM(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawURL   string
+		explicit model.UTM
+		defaults model.UTM
+		want     string
+	}{
+		{
+			name:   "no utm leaves url untouched",
+			rawURL: "https://example.com/a?b=1#top",
+			want:   "https://example.com/a?b=1#top",
+		},
+		{
+			name:     "appends to url without query",
+			rawURL:   "https://example.com/a",
+			explicit: model.UTM{Source: "newsletter", Medium: "email"},
+			want:     "https://example.com/a?utm_source=newsletter&utm_medium=email",
+		},
+		{
+			name:     "keeps existing params and fragment",
+			rawURL:   "https://example.com/sale?ref=home&id=7#top",
+			explicit: model.UTM{Campaign: "double11"},
+			want:     "https://example.com/sale?ref=home&id=7&utm_campaign=double11#top",
+		},
+		{
+			name:     "keeps raw encoding of other params",
+			rawURL:   "https://example.com/?q=a%20b&p=x+y&flag",
+			explicit: model.UTM{Source: "ads"},
+			want:     "https://example.com/?q=a%20b&p=x+y&flag&utm_source=ads",
+		},
+		{
+			name:     "explicit overrides existing utm",
+			rawURL:   "https://example.com/?utm_source=old&ref=1&utm_source=older",
+			explicit: model.UTM{Source: "new"},
+			want:     "https://example.com/?ref=1&utm_source=new",
+		},
+		{
+			name:     "defaults do not override existing utm",
+			rawURL:   "https://example.com/?utm_source=own",
+			defaults: model.UTM{Source: "campaign", Medium: "social"},
+			want:     "https://example.com/?utm_source=own&utm_medium=social",
+		},
+		{
+			name:     "explicit beats defaults",
+			rawURL:   "https://example.com/",
+			explicit: model.UTM{Medium: "email"},
+			defaults: model.UTM{Source: "campaign", Medium: "social"},
+			want:     "https://example.com/?utm_source=campaign&utm_medium=email",
+		},
+		{
+			name:     "encoded existing key counts as present",
+			rawURL:   "https://example.com/?utm%5Fsource=own",
+			defaults: model.UTM{Source: "campaign"},
+			want:     "https://example.com/?utm%5Fsource=own",
+		},
+		{
+			name:     "escapes values",
+			rawURL:   "https://example.com/",
+			explicit: model.UTM{Content: "a b&c"},
+			want:     "https://example.com/?utm_content=a+b%26c",
+		},
+		{
+			name:     "all params in standard order",
+			rawURL:   "https://example.com/#x",
+			explicit: model.UTM{Content: "e", Term: "d", Campaign: "c", Medium: "b", Source: "a"},
+			want:     "https://example.com/?utm_source=a&utm_medium=b&utm_campaign=c&utm_term=d&utm_content=e#x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUTM(tt.rawURL, tt.explicit, tt.defaults)
+			if err != nil {
+				t.Fatalf("applyUTM: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("applyUTM(%q) = %q, want %q", tt.rawURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyUTMInvalidURL(t *testing.T) {
+	_, err := applyUTM("https://exa mple.com/%zz", model.UTM{Source: "a"}, model.UTM{})
+	if !errors.Is(err, ErrURLInvalid) {
+		t.Errorf("applyUTM error = %v, want ErrURLInvalid", err)
+	}
+}
+
+func TestParseUTM(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  *types.UTMParams
+		want    model.UTM
+		wantErr bool
+	}{
+		{name: "nil", params: nil, want: model.UTM{}},
+		{
+			name:   "trims spaces",
+			params: &types.UTMParams{Source: " news ", Campaign: "\tsale\n"},
+			want:   model.UTM{Source: "news", Campaign: "sale"},
+		},
+		{
+			name:   "max length in runes",
+			params: &types.UTMParams{Term: strings.Repeat("词", maxUTMLength)},
+			want:   model.UTM{Term: strings.Repeat("词", maxUTMLength)},
+		},
+		{
+			name:    "too long",
+			params:  &types.UTMParams{Content: strings.Repeat("a", maxUTMLength+1)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUTM(tt.params)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUTMInvalid) {
+					t.Errorf("parseUTM error = %v, want ErrUTMInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUTM: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseUTM = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractUTM(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		want   model.UTM
+	}{
+		{name: "none", rawURL: "https://example.com/?a=1", want: model.UTM{}},
+		{
+			name:   "decodes values",
+			rawURL: "https://example.com/?utm_source=a+b&utm_medium=e%26f",
+			want:   model.UTM{Source: "a b", Medium: "e&f"},
+		},
+		{
+			name:   "first duplicate wins",
+			rawURL: "https://example.com/?utm_campaign=one&utm_campaign=two",
+			want:   model.UTM{Campaign: "one"},
+		},
+		{
+			name:   "truncates long values",
+			rawURL: "https://example.com/?utm_term=" + strings.Repeat("x", maxUTMLength+10),
+			want:   model.UTM{Term: strings.Repeat("x", maxUTMLength)},
+		},
+		{name: "invalid url", rawURL: "https://exa mple.com/", want: model.UTM{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractUTM(tt.rawURL); got != tt.want {
+				t.Errorf("extractUTM(%q) = %+v, want %+v", tt.rawURL, got, tt.want)
+			}
+		})
+	}
+}
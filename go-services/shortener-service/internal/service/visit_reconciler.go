@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+)
+
+// 每批扫描和合并的短链码数量
+const reconcileBatch = 500
+
+// VisitReconciler 访问计数合并任务
+// 定期扫描 redirect-service 写入Redis的 visit:count:<code>，原子取出后批量累加到 short_links.visit_count，
+// 写库失败时把计数加回Redis；多个实例同时运行时每个计数只会被一个实例取出。
+// 同时把访问额度已用尽的短链接在数据库中禁用
+type VisitReconciler struct {
+	redisRepo repo.RedisRepo
+	dbRepo    repo.ShortLinkRepo
+	interval  time.Duration
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+// NewVisitReconciler 创建访问计数合并任务，interval 为合并间隔(秒)
+func NewVisitReconciler(redisRepo repo.RedisRepo, dbRepo repo.ShortLinkRepo, interval int) *VisitReconciler {
+	if interval <= 0 {
+		interval = 1
+	}
+	return &VisitReconciler{
+		redisRepo: redisRepo,
+		dbRepo:    dbRepo,
+		interval:  time.Duration(interval) * time.Second,
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start 启动定期合并
+func (r *VisitReconciler) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Reconcile(context.Background()); err != nil {
+					log.Printf("Failed to reconcile visit counts: %v", err)
+				}
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期合并
+func (r *VisitReconciler) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	<-r.done
+}
+
+// Reconcile 执行一轮完整扫描合并，再禁用额度已用尽的短链接
+func (r *VisitReconciler) Reconcile(ctx context.Context) error {
+	var cursor uint64
+	for {
+		codes, next, err := r.redisRepo.ScanVisitCodes(ctx, cursor, reconcileBatch)
+		if err != nil {
+			return err
+		}
+		if err := r.fold(ctx, codes); err != nil {
+			return err
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	return r.disableExhausted(ctx)
+}
+
+// disableExhausted 禁用额度已用尽的短链接并清除缓存
+// 额度在 redirect-service 中原子占用，禁用只是把结果落库，不影响限额的准确性；
+// 写库失败的短链码放回集合并结束本轮，避免额度和缓存过期后短链接重新可访问
+func (r *VisitReconciler) disableExhausted(ctx context.Context) error {
+	for {
+		exhausted, err := r.redisRepo.TakeExhaustedCodes(ctx, reconcileBatch)
+		if err != nil || len(exhausted) == 0 {
+			return err
+		}
+
+		disabled := 0
+		var failed []string
+		var lastErr error
+		for code, used := range exhausted {
+			ok, err := r.dbRepo.DisableExhausted(ctx, code, used)
+			if err != nil {
+				failed = append(failed, code)
+				lastErr = err
+				continue
+			}
+			if !ok {
+				continue
+			}
+			_ = r.redisRepo.DeleteShortLink(ctx, &model.ShortLink{ShortCode: code})
+			disabled++
+		}
+		if disabled > 0 {
+			log.Printf("Disabled %d short links that reached their visit limit", disabled)
+		}
+		if len(failed) > 0 {
+			if err := r.redisRepo.RestoreExhaustedCodes(ctx, failed); err != nil {
+				log.Printf("Failed to restore exhausted short links %v: %v", failed, err)
+			}
+			return fmt.Errorf("failed to disable %d exhausted short links: %w", len(failed), lastErr)
+		}
+	}
+}
+
+// fold 取出一批计数并写入数据库
+func (r *VisitReconciler) fold(ctx context.Context, codes []string) error {
+	visits, err := r.redisRepo.TakeVisitCounts(ctx, codes)
+	if err != nil || len(visits) == 0 {
+		return err
+	}
+
+	if err := r.dbRepo.AddVisitCounts(ctx, visits); err != nil {
+		if restoreErr := r.redisRepo.RestoreVisitCounts(ctx, visits); restoreErr != nil {
+			log.Printf("Failed to restore %d visit counters, counts lost: %v", len(visits), restoreErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"shortener-service/internal/model"
+	"shortener-service/internal/repo"
+)
+
+// fakeExhaustedRedis 内存中的额度用尽集合，只实现禁用用到的方法
+type fakeExhaustedRedis struct {
+	repo.RedisRepo
+	exhausted map[string]uint64
+}
+
+func (r *fakeExhaustedRedis) TakeExhaustedCodes(ctx context.Context, count int64) (map[string]uint64, error) {
+	taken := make(map[string]uint64)
+	for code, used := range r.exhausted {
+		if int64(len(taken)) >= count {
+			break
+		}
+		taken[code] = used
+		delete(r.exhausted, code)
+	}
+	return taken, nil
+}
+
+func (r *fakeExhaustedRedis) RestoreExhaustedCodes(ctx context.Context, codes []string) error {
+	for _, code := range codes {
+		r.exhausted[code] = 0
+	}
+	return nil
+}
+
+func (r *fakeExhaustedRedis) DeleteShortLink(ctx context.Context, link *model.ShortLink) error {
+	return nil
+}
+
+// fakeDisableRepo 记录被禁用的短链码，failing 中的短链码写库失败
+type fakeDisableRepo struct {
+	repo.ShortLinkRepo
+	failing  map[string]bool
+	disabled map[string]bool
+}
+
+func (r *fakeDisableRepo) DisableExhausted(ctx context.Context, code string, used uint64) (bool, error) {
+	if r.failing[code] {
+		return false, errors.New("lock wait timeout")
+	}
+	r.disabled[code] = true
+	return true, nil
+}
+
+func TestDisableExhaustedRestoresFailedCodes(t *testing.T) {
+	redis := &fakeExhaustedRedis{exhausted: map[string]uint64{"ok1": 3, "bad": 1, "ok2": 5}}
+	db := &fakeDisableRepo{failing: map[string]bool{"bad": true}, disabled: make(map[string]bool)}
+	r := NewVisitReconciler(redis, db, 1)
+
+	if err := r.disableExhausted(context.Background()); err == nil {
+		t.Fatal("disableExhausted succeeded, want error for the failed code")
+	}
+	if !db.disabled["ok1"] || !db.disabled["ok2"] {
+		t.Errorf("disabled = %v, want ok1 and ok2", db.disabled)
+	}
+	if _, ok := redis.exhausted["bad"]; !ok || len(redis.exhausted) != 1 {
+		t.Errorf("exhausted set = %v, want only the failed code", redis.exhausted)
+	}
+
+	delete(db.failing, "bad")
+	if err := r.disableExhausted(context.Background()); err != nil {
+		t.Fatalf("disableExhausted retry: %v", err)
+	}
+	if !db.disabled["bad"] || len(redis.exhausted) != 0 {
+		t.Errorf("retry left disabled = %v, exhausted set = %v", db.disabled, redis.exhausted)
+	}
+}
@@ -4,11 +4,20 @@ import "time"
 
 // ShortenRequest 短链生成请求
 type ShortenRequest struct {
-	OriginalURL string     `json:"original_url" binding:"required,url"`
-	CustomCode  string     `json:"custom_code,omitempty"`
-	Title       string     `json:"title,omitempty"`
-	Description string     `json:"description,omitempty"`
-	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	OriginalURL   string     `json:"original_url" binding:"required,url"`
+	CustomCode    string     `json:"custom_code,omitempty"`
+	Title         string     `json:"title,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	StartAt       *time.Time `json:"start_at,omitempty"` // 生效时间，之前访问返回“尚未开放”
+	ExpireAt      *time.Time `json:"expire_at,omitempty"`
+	MaxVisits     *uint64    `json:"max_visits,omitempty"`     // 访问次数上限，达到后自动禁用
+	OneTime       bool       `json:"one_time,omitempty"`       // 为true时为阅后即焚链接，等同于 max_visits=1
+	Password      string     `json:"password,omitempty"`       // 访问密码，只保存bcrypt哈希
+	CampaignID    *uint64    `json:"campaign_id,omitempty"`    // 所属活动
+	Tags          []string   `json:"tags,omitempty"`           // 标签名，不存在的标签自动创建
+	UTM           *UTMParams `json:"utm,omitempty"`            // 合并到目标地址的UTM参数，覆盖地址中的同名参数
+	Dedupe        bool       `json:"dedupe,omitempty"`         // 为true时复用本人已有的相同URL短链
+	StripFragment bool       `json:"strip_fragment,omitempty"` // 为true时去掉URL中的锚点
 }
 
 // ShortenResponse 短链生成响应
@@ -19,14 +28,25 @@ type ShortenResponse struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// BatchShortenRequest 批量短链生成请求
+// BatchShortenRequest 批量短链生成请求，urls 和 items 合计最多100条
 type BatchShortenRequest struct {
-	URLs []string `json:"urls" binding:"required,min=1,max=100"`
+	URLs   []string         `json:"urls,omitempty"`   // 仅指定URL的简写形式，排在 items 之后
+	Items  []ShortenRequest `json:"items,omitempty"`  // 完整的单条创建参数
+	Atomic bool             `json:"atomic,omitempty"` // 为true时任一条失败则全部不创建
+}
+
+// BatchItemResult 批量创建中单条的结果，与请求中的顺序一致
+type BatchItemResult struct {
+	Index   int              `json:"index"`
+	Status  int              `json:"status"` // 对应单条创建时的HTTP状态码
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    *ShortenResponse `json:"data,omitempty"`
 }
 
 // BatchShortenResponse 批量短链生成响应
 type BatchShortenResponse struct {
-	Results []ShortenResponse `json:"results"`
+	Results []BatchItemResult `json:"results"`
 	Success int               `json:"success"`
 	Failed  int               `json:"failed"`
 }
@@ -38,14 +58,66 @@ type GetLinkRequest struct {
 
 // GetLinkResponse 查询短链响应
 type GetLinkResponse struct {
-	ShortCode   string     `json:"short_code"`
-	OriginalURL string     `json:"original_url"`
-	Title       string     `json:"title,omitempty"`
-	Description string     `json:"description,omitempty"`
-	VisitCount  uint64     `json:"visit_count"`
-	Status      int8       `json:"status"`
-	ExpireAt    *time.Time `json:"expire_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ShortCode         string     `json:"short_code"`
+	ShortURL          string     `json:"short_url"`
+	OriginalURL       string     `json:"original_url"`
+	Title             string     `json:"title,omitempty"`
+	Description       string     `json:"description,omitempty"`
+	VisitCount        uint64     `json:"visit_count"`
+	LastVisitedAt     *time.Time `json:"last_visited_at,omitempty"`
+	MaxVisits         *uint64    `json:"max_visits,omitempty"`
+	PasswordProtected bool       `json:"password_protected,omitempty"`
+	CampaignID        *uint64    `json:"campaign_id,omitempty"`
+	Tags              []string   `json:"tags,omitempty"`
+	UTM               *UTMParams `json:"utm,omitempty"`
+	UserID            *uint64    `json:"user_id,omitempty"` // 创建者，匿名创建时为空
+	Status            int8       `json:"status"`
+	StartAt           *time.Time `json:"start_at,omitempty"`
+	ExpireAt          *time.Time `json:"expire_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// UpdateLinkRequest 更新短链请求（仅更新非空字段）
+type UpdateLinkRequest struct {
+	OriginalURL    *string    `json:"original_url,omitempty"`
+	Title          *string    `json:"title,omitempty"`
+	Description    *string    `json:"description,omitempty"`
+	Status         *int8      `json:"status,omitempty"`
+	StartAt        *time.Time `json:"start_at,omitempty"`
+	ClearStart     bool       `json:"clear_start,omitempty"` // 为true时清除生效时间，立即生效
+	ExpireAt       *time.Time `json:"expire_at,omitempty"`
+	ClearExpire    bool       `json:"clear_expire,omitempty"`     // 为true时清除过期时间
+	MaxVisits      *uint64    `json:"max_visits,omitempty"`       // 新的访问次数上限，已有访问计入
+	ClearMaxVisits bool       `json:"clear_max_visits,omitempty"` // 为true时取消访问次数上限
+	Password       *string    `json:"password,omitempty"`         // 新的访问密码
+	ClearPassword  bool       `json:"clear_password,omitempty"`   // 为true时取消访问密码
+	CampaignID     *uint64    `json:"campaign_id,omitempty"`      // 移入的活动，活动的UTM模板补全目标地址中缺少的UTM参数
+	ClearCampaign  bool       `json:"clear_campaign,omitempty"`   // 为true时移出活动
+	Tags           *[]string  `json:"tags,omitempty"`             // 替换全部标签，空数组表示清除
+	UTM            *UTMParams `json:"utm,omitempty"`              // 合并到目标地址的UTM参数
+	StripFragment  bool       `json:"strip_fragment,omitempty"`   // 为true时去掉URL中的锚点
+}
+
+// ListLinksRequest 短链列表查询请求
+type ListLinksRequest struct {
+	Cursor      string     // 上一页返回的 next_cursor
+	Limit       int        // 每页数量
+	SortBy      string     // created_at | visit_count
+	Order       string     // asc | desc
+	Status      *int8      // 状态过滤
+	Expired     *bool      // 是否过期
+	CreatedFrom *time.Time // 创建时间起(含)
+	CreatedTo   *time.Time // 创建时间止(不含)
+	Query       string     // 搜索关键字
+	CampaignID  *uint64    // 所属活动
+	Tags        []string   // 同时带有全部标签
+}
+
+// ListLinksResponse 短链列表查询响应
+type ListLinksResponse struct {
+	Links      []GetLinkResponse `json:"links"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
 }
 
 // CommonResponse 通用响应
@@ -54,3 +126,59 @@ type CommonResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// ImportJobResponse 导入任务进度响应
+type ImportJobResponse struct {
+	JobID      string     `json:"job_id"`
+	Status     string     `json:"status"` // pending | running | completed | failed
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ResultURL  string     `json:"result_url,omitempty"` // 任务完成后可下载结果CSV
+}
+
+// UTMParams UTM参数，空字段表示不设置
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// CampaignRequest 创建或更新活动请求
+type CampaignRequest struct {
+	Name        *string    `json:"name,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	UTM         *UTMParams `json:"utm,omitempty"` // 替换UTM模板，空对象表示清除
+}
+
+// CampaignResponse 活动响应，包含活动下短链接的汇总数据
+type CampaignResponse struct {
+	ID            uint64     `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description,omitempty"`
+	UTM           *UTMParams `json:"utm,omitempty"`
+	LinkCount     uint64     `json:"link_count"`
+	VisitCount    uint64     `json:"visit_count"` // 访问次数之和；详情中包含尚未合并到数据库的计数（短链接过多时除外），列表中只含已落库的部分
+	LastVisitedAt *time.Time `json:"last_visited_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TagRequest 创建或重命名标签请求
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+// TagResponse 标签响应
+type TagResponse struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	LinkCount uint64    `json:"link_count"`
+	CreatedAt time.Time `json:"created_at"`
+}